@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/camru/greenlight/internal/data"
+)
+
+// Define a custom contextKey type, with the underlying type string.
+type contextKey string
+
+// Convert the string "user" to a contextKey type and assign it to the
+// userContextKey constant. We'll use this constant as the key for getting and
+// setting user information in the request context.
+const userContextKey = contextKey("user")
+
+// The active library is stored under its own key once the requireLibraryRole()
+// middleware has checked the user's membership.
+const libraryContextKey = contextKey("library")
+
+// The contextSetUser() method returns a new copy of the request with the
+// provided User struct added to the context. Note that we use our
+// userContextKey constant as the key.
+func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	return r.WithContext(ctx)
+}
+
+// The contextGetUser() retrieves the User struct from the request context. We
+// only call it after the authenticate() middleware has run, which always sets
+// a user (anonymous or not), so a missing value means a handler has been wired
+// up wrongly. That's a bug rather than a runtime condition, so we panic.
+func (app *application) contextGetUser(r *http.Request) *data.User {
+	user, ok := r.Context().Value(userContextKey).(*data.User)
+	if !ok {
+		panic("missing user value in request context")
+	}
+
+	return user
+}
+
+// The contextSetLibrary() method returns a new copy of the request with the
+// active library added to the context.
+func (app *application) contextSetLibrary(r *http.Request, library *data.Library) *http.Request {
+	ctx := context.WithValue(r.Context(), libraryContextKey, library)
+	return r.WithContext(ctx)
+}
+
+// The contextGetLibrary() retrieves the active library from the request
+// context. Like contextGetUser(), it panics if the value is missing, because
+// that means a handler was registered without the requireLibraryRole()
+// middleware.
+func (app *application) contextGetLibrary(r *http.Request) *data.Library {
+	library, ok := r.Context().Value(libraryContextKey).(*data.Library)
+	if !ok {
+		panic("missing library value in request context")
+	}
+
+	return library
+}
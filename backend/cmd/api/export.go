@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/camru/greenlight/internal/data"
+	"github.com/camru/greenlight/internal/validator"
+)
+
+// exportPageSize is how many records are read from the database at a time
+// while exporting.
+const exportPageSize = 100
+
+// exportHeader is the first row of the export, naming each column.
+var exportHeader = []string{
+	"title", "year", "mediaType", "imdbID", "watched", "dateWatched", "seasons", "tags", "rating",
+	"imdbRating", "rtRating", "metacritic",
+}
+
+// GET
+func (app *application) exportMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	// The export takes the same watched, mediaType and sort parameters as
+	// GET /v1/movies, and always includes every matching record.
+	var input struct {
+		data.MovieFilters
+		data.Filters
+	}
+
+	qs := r.URL.Query()
+
+	input.Watched = app.readString(qs, "watched", "")
+	input.MediaType = app.readString(qs, "mediaType", "")
+
+	input.Filters.Page = 1
+	input.Filters.PageSize = exportPageSize
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = movieSortSafelist
+
+	v := validator.New()
+
+	data.ValidateMovieFilters(v, input.MovieFilters)
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	libraryID := app.contextGetLibrary(r).ID
+
+	// Fetch the first page before writing anything, so that if it fails we
+	// can still send a proper error response.
+	movies, metadata, err := app.models.Movies.GetAll(libraryID, input.MovieFilters, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="media.csv"`)
+
+	// csv.Writer uses "\n" line endings by default. RFC 4180 calls for CRLF.
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+
+	err = cw.Write(exportHeader)
+	if err != nil {
+		app.logError(r, err)
+		return
+	}
+
+	// Page through the rest with cursors, flushing each page to the client as
+	// we go. Once the response has started there's no way to report an error
+	// to the client, so we can only log it and stop.
+	for {
+		for _, movie := range movies {
+			err = cw.Write(exportRow(movie))
+			if err != nil {
+				app.logError(r, err)
+				return
+			}
+		}
+
+		cw.Flush()
+		if err = cw.Error(); err != nil {
+			app.logError(r, err)
+			return
+		}
+
+		if metadata.NextCursor == "" {
+			return
+		}
+
+		input.Filters.Cursor = metadata.NextCursor
+
+		movies, metadata, err = app.models.Movies.GetAll(libraryID, input.MovieFilters, input.Filters)
+		if err != nil {
+			app.logError(r, err)
+			return
+		}
+	}
+}
+
+// exportRow turns a media record into a row of the export. Season finish
+// dates are separated by semicolons, with an empty entry for each season
+// that isn't finished, as are tags. The external ratings are the scores out
+// of 100, left empty when a source hasn't rated the title, and so is our own
+// rating when it hasn't been given.
+func exportRow(movie *data.Movie) []string {
+	seasons := make([]string, len(movie.DateWatchedSeasons))
+	for i, date := range movie.DateWatchedSeasons {
+		seasons[i] = exportDate(date)
+	}
+
+	scores := map[string]string{}
+	for _, rating := range movie.Ratings {
+		if rating.Score != nil {
+			scores[rating.Source] = strconv.FormatFloat(*rating.Score, 'f', -1, 64)
+		}
+	}
+
+	rating := ""
+	if movie.Rating != 0 {
+		rating = strconv.FormatFloat(float64(movie.Rating), 'f', -1, 32)
+	}
+
+	row := []string{
+		movie.Title,
+		movie.Year,
+		movie.MediaType,
+		movie.ImdbID,
+		strconv.FormatBool(movie.Watched),
+		exportDate(movie.DateWatched),
+		strings.Join(seasons, ";"),
+		strings.Join(movie.Tags, ";"),
+		rating,
+		scores[data.RatingSourceIMDb],
+		scores[data.RatingSourceRottenTomatoes],
+		scores[data.RatingSourceMetacritic],
+	}
+
+	for i, cell := range row {
+		row[i] = exportCell(cell)
+	}
+
+	return row
+}
+
+// exportCell stops spreadsheets from running a cell as a formula. Titles and
+// tags are whatever users typed in, and a cell starting with =, +, -, @, a
+// tab or a carriage return can be taken as a formula by Excel and friends, so
+// those get a leading ' to mark them as text.
+func exportCell(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// exportDate formats a date for the export, leaving it empty if it isn't set.
+func exportDate(date data.Date) string {
+	if date.IsZero() {
+		return ""
+	}
+	return date.String()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/camru/greenlight/internal/data"
+)
+
+func TestExportCell(t *testing.T) {
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"Elf", "Elf"},
+		{"", ""},
+		{"=HYPERLINK(\"http://example.com\")", "'=HYPERLINK(\"http://example.com\")"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@SUM(A1:A2)", "'@SUM(A1:A2)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+		{"Dash & Lily", "Dash & Lily"},
+		{"2 + 2", "2 + 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cell, func(t *testing.T) {
+			if got := exportCell(tt.cell); got != tt.want {
+				t.Errorf("exportCell(%q) = %q; want %q", tt.cell, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportRow(t *testing.T) {
+	date := func(s string) data.Date {
+		d, err := data.ParseDate(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	tests := []struct {
+		name  string
+		movie data.Movie
+		want  []string
+	}{
+		{
+			name: "watched movie",
+			movie: data.Movie{
+				Title:       "Elf",
+				Year:        "2003",
+				MediaType:   "movie",
+				ImdbID:      "tt0319343",
+				Watched:     true,
+				DateWatched: date("2022-12-24"),
+				Tags:        []string{"christmas", "comedy"},
+				Rating:      8.5,
+				Ratings: data.Ratings{
+					data.NewRating(data.RatingSourceIMDb, "7.1/10"),
+					data.NewRating(data.RatingSourceRottenTomatoes, "85%"),
+					data.NewRating(data.RatingSourceMetacritic, "64/100"),
+				},
+			},
+			want: []string{"Elf", "2003", "movie", "tt0319343", "true", "2022-12-24", "", "christmas;comedy", "8.5", "71", "85", "64"},
+		},
+		{
+			name: "unrated with missing sources",
+			movie: data.Movie{
+				Title:     "Dash & Lily",
+				Year:      "2020",
+				MediaType: "series",
+				ImdbID:    "tt9741310",
+				Ratings: data.Ratings{
+					data.NewRating(data.RatingSourceIMDb, "7.3/10"),
+					data.NewRating(data.RatingSourceMetacritic, "N/A"),
+				},
+			},
+			want: []string{"Dash & Lily", "2020", "series", "tt9741310", "false", "", "", "", "", "73", "", ""},
+		},
+		{
+			name: "unfinished seasons",
+			movie: data.Movie{
+				Title:              "Dash & Lily",
+				MediaType:          "series",
+				Watched:            true,
+				DateWatched:        date("2021-01-02"),
+				DateWatchedSeasons: []data.Date{date("2020-12-20"), {}, date("2021-01-02"), {}},
+			},
+			want: []string{"Dash & Lily", "", "series", "", "true", "2021-01-02", "2020-12-20;;2021-01-02;", "", "", "", "", ""},
+		},
+		{
+			name: "formulas escaped",
+			movie: data.Movie{
+				Title:     "=1+1",
+				MediaType: "movie",
+				Tags:      []string{"@home", "christmas"},
+			},
+			want: []string{"'=1+1", "", "movie", "", "false", "", "", "'@home;christmas", "", "", "", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := exportRow(&tt.movie)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
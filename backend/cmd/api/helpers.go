@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/camru/greenlight/internal/data"
 	"github.com/camru/greenlight/internal/validator"
 	"github.com/julienschmidt/httprouter"
 )
@@ -38,6 +39,19 @@ func (app *application) readIdParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+// readNamedIdParam is like readIdParam(), for routes with more than one id,
+// such as /v1/movies/:id/watches/:watchID.
+func (app *application) readNamedIdParam(r *http.Request, name string) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.ParseInt(params.ByName(name), 10, 64)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid %s parameter", name)
+	}
+
+	return id, nil
+}
+
 // This is for when a client sends a GET requests and we want to send back a
 // JSON response. This takes the destination http.ResponseWriter, the HTTP
 // status code to send, the data to encode to JSON, and a header map containing
@@ -184,3 +198,62 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 
 	return i
 }
+
+// The readFloat() helper reads a string value from the query string and
+// converts it to a float64 before returning. Like readInt(), it returns the
+// provided default value if no matching key could be found, and records an
+// error message in the Validator instance if the value isn't a number.
+func (app *application) readFloat(qs url.Values, key string, defaultValue float64, v *validator.Validator) float64 {
+
+	str := qs.Get(key)
+
+	if str == "" {
+		return defaultValue
+	}
+
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		v.AddError(key, "must be a number")
+		return defaultValue
+	}
+
+	return f
+}
+
+// readMovieForLibrary fetches the media record named by the :id parameter
+// from the request's library. If it can't, it sends the error response itself
+// and returns false.
+func (app *application) readMovieForLibrary(w http.ResponseWriter, r *http.Request) (*data.Movie, bool) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	movie, err := app.models.Movies.Get(id, app.contextGetLibrary(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return movie, true
+}
+
+// runRecovered calls fn, recovering and logging any panic so that a bad record
+// can't bring the whole server down, in the same way that net/http does for
+// handlers. Background workers call it once per run, so that one panic only
+// costs that run and the worker carries on with the next.
+func (app *application) runRecovered(name string, fn func()) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.logger.Printf("%s panicked: %v", name, err)
+		}
+	}()
+
+	fn()
+}
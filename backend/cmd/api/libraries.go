@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/camru/greenlight/internal/data"
+	"github.com/camru/greenlight/internal/validator"
+)
+
+// POST
+func (app *application) createLibraryHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string `json:"name"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	library := &data.Library{Name: input.Name}
+
+	v := validator.New()
+
+	if data.ValidateLibrary(v, library); !v.Valid() {
+		app.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// The user creating the library becomes its owner.
+	err = app.models.Libraries.Insert(library, app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/movies?library_id=%d", library.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"library": library}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GET
+func (app *application) listLibrariesHandler(w http.ResponseWriter, r *http.Request) {
+	libraries, err := app.models.Libraries.GetAllForUser(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"libraries": libraries}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// POST
+func (app *application) createInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	// Only owners can invite people into a library.
+	library, err := app.models.Libraries.GetForUser(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !library.Allows(data.RoleOwner) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	invitation := &data.Invitation{
+		LibraryID: library.ID,
+		InvitedBy: user.ID,
+		Email:     input.Email,
+		Role:      input.Role,
+	}
+
+	v := validator.New()
+
+	if data.ValidateInvitation(v, invitation); !v.Valid() {
+		app.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// There's no mailer, so the token is handed back to the owner to pass on
+	// to the person they're inviting. It is valid for a week.
+	err = app.models.Libraries.NewInvitation(invitation, 7*24*time.Hour)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"invitation": invitation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// PUT
+func (app *application) acceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, input.TokenPlaintext); !v.Valid() {
+		app.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	library, err := app.models.Libraries.AcceptInvitation(input.TokenPlaintext, app.contextGetUser(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired invitation token")
+			app.FailedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"library": library}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/camru/greenlight/internal/metadata"
+	"github.com/camru/greenlight/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// GET
+func (app *application) searchLookupHandler(w http.ResponseWriter, r *http.Request) {
+	title := app.readString(r.URL.Query(), "title", "")
+
+	v := validator.New()
+
+	v.Check(title != "", "title", "must be provided")
+	v.Check(len(title) <= 500, "title", "must not be more than 500 bytes long")
+
+	if !v.Valid() {
+		app.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	results, err := app.metadata.Search(r.Context(), title)
+	if err != nil {
+		app.badGatewayResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GET
+func (app *application) showLookupHandler(w http.ResponseWriter, r *http.Request) {
+	imdbID := httprouter.ParamsFromContext(r.Context()).ByName("imdbID")
+
+	if !validator.Matches(imdbID, validator.ImdbIDRX) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	title, err := app.metadata.Lookup(r.Context(), imdbID)
+	if err != nil {
+		switch {
+		case errors.Is(err, metadata.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.badGatewayResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"title": title}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -2,18 +2,23 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"errors"
 	"flag"
-	"fmt"
 	"log"
-	"net/http"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	// Import the pq driver so that it can register itself with the database/sql
 	// package. Note that we alias this import to the blank identifier, to stop
 	// the Go compiler complaining that the package isn't being used.
 	"github.com/camru/greenlight/internal/data"
+	"github.com/camru/greenlight/internal/metadata"
+	"github.com/camru/greenlight/internal/posters"
+	"github.com/camru/greenlight/internal/validator"
 	_ "github.com/lib/pq"
 )
 
@@ -37,6 +42,50 @@ type config struct {
 		maxIdleConns int
 		maxIdleTime  string
 	}
+	// The OMDb settings used by the metadata lookups. When fake is set we
+	// start an in-process stand-in for OMDb instead, which is handy for working
+	// offline.
+	omdb struct {
+		url    string
+		apiKey string
+		fake   bool
+	}
+	// How often the background worker looks for stale ratings on the to-watch
+	// list, and how old ratings have to be before they're refetched. An
+	// interval of 0 turns the worker off.
+	ratings struct {
+		refreshInterval time.Duration
+		maxAge          time.Duration
+	}
+	// The TMDB settings used to find out where titles can be watched. As with
+	// OMDb, fake starts an in-process stand-in instead.
+	tmdb struct {
+		url    string
+		apiKey string
+		fake   bool
+	}
+	// The region (country code) to look up watch providers in by default,
+	// how long to cache them for, and how often to refresh the stale ones in
+	// the background. subscribed is the list of provider slugs used for
+	// available=mine when a user hasn't set their own.
+	providers struct {
+		region          string
+		maxAge          time.Duration
+		refreshInterval time.Duration
+		subscribed      []string
+	}
+	// Where posters are cached. Caching is turned off if it's empty. secret
+	// signs the poster URLs handed out in media records, which is how <img>
+	// tags (which can't send an Authorization header) are let in.
+	posters struct {
+		dir    string
+		secret string
+	}
+	// Whether saving a record with a tag the library doesn't have creates the
+	// tag, instead of being rejected.
+	tags struct {
+		autoCreate bool
+	}
 }
 
 // Define an application struct to hold the dependencies for our HTTP handlers,
@@ -44,9 +93,16 @@ type config struct {
 // config struct and a logger, but it will grow to include a lot more as our
 // build progresses.
 type application struct {
-	config config
-	logger *log.Logger
-	models data.Models
+	config   config
+	logger   *log.Logger
+	models   data.Models
+	metadata metadata.Provider
+	// watchProviders is where we look up streaming, rental and purchase
+	// options for titles.
+	watchProviders metadata.WatchProviderSource
+	// posters is the local poster cache, or nil if it's turned off.
+	posters *posters.Cache
+	wg      sync.WaitGroup
 }
 
 func main() {
@@ -65,12 +121,62 @@ func main() {
 	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "PostgreSQL max idle connections")
 	flag.StringVar(&cfg.db.maxIdleTime, "db-max-idle-time", "15m", "PostgreSQL max idle time")
 
+	flag.StringVar(&cfg.omdb.url, "omdb-url", "https://www.omdbapi.com/", "OMDb API base URL")
+	flag.StringVar(&cfg.omdb.apiKey, "omdb-api-key", os.Getenv("OMDB_API_KEY"), "OMDb API key")
+	flag.BoolVar(&cfg.omdb.fake, "omdb-fake", false, "Serve metadata lookups from a local fake OMDb server")
+
+	flag.DurationVar(&cfg.ratings.refreshInterval, "ratings-refresh-interval", 6*time.Hour, "How often to refresh stale ratings (0 to disable)")
+	flag.DurationVar(&cfg.ratings.maxAge, "ratings-max-age", 30*24*time.Hour, "Refresh ratings older than this")
+
+	flag.StringVar(&cfg.tmdb.url, "tmdb-url", "https://api.themoviedb.org/3", "TMDB API base URL")
+	flag.StringVar(&cfg.tmdb.apiKey, "tmdb-api-key", os.Getenv("TMDB_API_KEY"), "TMDB API key")
+	flag.BoolVar(&cfg.tmdb.fake, "tmdb-fake", false, "Serve watch provider lookups from a local fake TMDB server")
+
+	flag.StringVar(&cfg.providers.region, "providers-region", "US", "Default region (country code) for watch providers")
+	flag.DurationVar(&cfg.providers.maxAge, "providers-max-age", 24*time.Hour, "How long to cache watch providers for")
+	flag.DurationVar(&cfg.providers.refreshInterval, "providers-refresh-interval", time.Hour, "How often to refresh stale watch providers of to-watch titles (0 to disable)")
+	flag.Func("providers-subscribed", "Comma separated provider slugs subscribed to, for users who haven't set their own", func(val string) error {
+		// Allow spaces after the commas, and refuse to start with a slug that
+		// could never match, rather than quietly matching nothing.
+		slugs := []string{}
+		for _, slug := range strings.Split(val, ",") {
+			slugs = append(slugs, strings.TrimSpace(slug))
+		}
+
+		v := validator.New()
+		if data.ValidateSubscriptions(v, slugs); !v.Valid() {
+			return errors.New(v.Errors["providers"])
+		}
+
+		cfg.providers.subscribed = slugs
+		return nil
+	})
+
+	flag.StringVar(&cfg.posters.dir, "posters-dir", "posters", "Directory to cache posters in (empty to disable)")
+	flag.StringVar(&cfg.posters.secret, "posters-secret", os.Getenv("POSTERS_SECRET"), "Key to sign poster URLs with (random if empty)")
+
+	flag.BoolVar(&cfg.tags.autoCreate, "tags-auto-create", false, "Create unknown tags when they're used, instead of rejecting them")
+
 	flag.Parse()
 
 	// Initialize a new logger which writes messages to the standard out stream,
 	// prefixed with the current date and time.
 	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
 
+	// Without a configured secret, poster URLs stop working whenever the
+	// server restarts, and clients have to fetch their records again.
+	if cfg.posters.secret == "" {
+		secret := make([]byte, 32)
+
+		_, err := rand.Read(secret)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		cfg.posters.secret = string(secret)
+		logger.Printf("no -posters-secret set, poster URLs won't survive a restart")
+	}
+
 	db, err := openDB(cfg)
 	if err != nil {
 		logger.Fatal(err)
@@ -84,29 +190,47 @@ func main() {
 	// established.
 	logger.Printf("database connection pool established")
 
+	if cfg.omdb.fake {
+		omdbServer := metadata.NewFakeOMDbServer()
+		defer omdbServer.Close()
+
+		cfg.omdb.url = omdbServer.URL
+		cfg.omdb.apiKey = "fake"
+
+		logger.Printf("using fake OMDb server on %s", omdbServer.URL)
+	}
+
+	if cfg.tmdb.fake {
+		tmdbServer := metadata.NewFakeTMDbServer()
+		defer tmdbServer.Close()
+
+		cfg.tmdb.url = tmdbServer.URL
+		cfg.tmdb.apiKey = "fake"
+
+		logger.Printf("using fake TMDB server on %s", tmdbServer.URL)
+	}
+
 	// Declare an instance of the application struct, containing the config
 	// struct and the logger.
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
-	}
+		config:   cfg,
+		logger:   logger,
+		models:   data.NewModels(db),
+		metadata: metadata.NewOMDbClient(cfg.omdb.url, cfg.omdb.apiKey),
 
-	// Declare a HTTP server with some sensible timeout settings,
-	// which listens on the port provided in the config struct and uses the
-	// servemux we created above as the handler.
-	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%d", cfg.port),
-		Handler:      app.routes(),
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		watchProviders: metadata.NewTMDbClient(cfg.tmdb.url, cfg.tmdb.apiKey),
 	}
 
-	logger.Printf("starting %s server on %s", cfg.env, srv.Addr)
+	if cfg.posters.dir != "" {
+		app.posters = posters.New(cfg.posters.dir)
+	}
 
-	err = srv.ListenAndServe()
-	logger.Fatal(err)
+	// Start the HTTP server and the background workers. serve() only returns
+	// once they have all shut down.
+	err = app.serve()
+	if err != nil {
+		logger.Fatal(err)
+	}
 }
 
 // The openDB() function returns a sql.DB connection pool.
@@ -1,15 +1,215 @@
 package main
 
-import "net/http"
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/camru/greenlight/internal/data"
+	"github.com/camru/greenlight/internal/validator"
+)
 
 func (app *application) enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		// Add the "Vary: Access-Control-Request-Method" header.
 		w.Header().Add("Vary", "Access-Control-Request-Method")
-		// w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
-		// w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		// w.WriteHeader(http.StatusOK)
+
+		// Sending an Authorization header makes the browser send a preflight
+		// request first, so answer those here with the methods and headers we
+		// accept.
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The authenticate() middleware looks for a bearer token or an API key in the
+// Authorization header and stores the user it belongs to in the request context. Requests
+// without an Authorization header carry on as the AnonymousUser.
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Add the "Vary: Authorization" header to the response. This indicates to
+		// any caches that the response may vary based on the value of the
+		// Authorization header in the request.
+		w.Header().Add("Vary", "Authorization")
+
+		// Retrieve the value of the Authorization header from the request. This
+		// will return the empty string "" if there is no such header found.
+		authorizationHeader := r.Header.Get("Authorization")
+
+		// If there is no Authorization header found, use the contextSetUser()
+		// helper that we just made to add the AnonymousUser to the request
+		// context. Then we call the next handler in the chain and return without
+		// executing any of the code below.
+		if authorizationHeader == "" {
+			r = app.contextSetUser(r, data.AnonymousUser)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Otherwise, we expect the value of the Authorization header to be in the
+		// format "Bearer <token>" or "ApiKey <key>". We try to split this into its
+		// constituent parts, and if the header isn't in the expected format we
+		// return a 401 Unauthorized response using the
+		// invalidAuthenticationTokenResponse() helper.
+		headerParts := strings.Split(authorizationHeader, " ")
+		if len(headerParts) != 2 {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		var user *data.User
+		var err error
+
+		// Validate the credential to make sure it is in a sensible format, and
+		// then retrieve the details of the user it belongs to.
+		v := validator.New()
+
+		switch headerParts[0] {
+		case "Bearer":
+			if data.ValidateTokenPlaintext(v, headerParts[1]); !v.Valid() {
+				app.invalidAuthenticationTokenResponse(w, r)
+				return
+			}
+			user, err = app.models.Users.GetForToken(data.ScopeAuthentication, headerParts[1])
+		case "ApiKey":
+			if data.ValidateAPIKeyPlaintext(v, headerParts[1]); !v.Valid() {
+				app.invalidAuthenticationTokenResponse(w, r)
+				return
+			}
+			user, err = app.models.Users.GetForAPIKey(headerParts[1])
+		default:
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		// Call the invalidAuthenticationTokenResponse() helper again if no
+		// matching record was found.
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.invalidAuthenticationTokenResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		// Call the contextSetUser() helper to add the user information to the
+		// request context.
+		r = app.contextSetUser(r, user)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The requireAuthenticatedUser() middleware rejects any request that was not
+// made by a logged in user.
+func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		if user.IsAnonymous() {
+			app.authenticationRequiredResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Note that the first parameter for the middleware function is the permission
+// code that we require the user to have.
+func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		// Retrieve the user from the request context.
+		user := app.contextGetUser(r)
+
+		// Get the slice of permissions for the user.
+		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		// Check if the slice includes the required permission. If it doesn't, then
+		// return a 403 Forbidden response.
+		if !permissions.Include(code) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		// Otherwise they have the required permission so we call the next handler in
+		// the chain.
+		next.ServeHTTP(w, r)
+	}
+
+	// Wrap this with the requireAuthenticatedUser() middleware before returning it.
+	return app.requireAuthenticatedUser(fn)
+}
+
+// The requireLibraryRole() middleware works out which library the request is
+// acting on and checks that the user holds at least the given role in it. The
+// library comes from the library_id query string parameter, falling back to
+// the user's personal library. It must run after the user has been
+// authenticated.
+func (app *application) requireLibraryRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		var library *data.Library
+		var err error
+
+		v := validator.New()
+
+		libraryID := app.readInt(r.URL.Query(), "library_id", 0, v)
+		if !v.Valid() {
+			app.FailedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		if libraryID == 0 {
+			library, err = app.models.Libraries.GetPersonalForUser(user.ID)
+		} else {
+			library, err = app.models.Libraries.GetForUser(int64(libraryID), user.ID)
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		if !library.Allows(role) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		r = app.contextSetLibrary(r, library)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The allowSignedURL() middleware sends requests carrying a signature in the
+// sig query string parameter to signed, and everything else to next. It's for
+// endpoints which browsers load directly (like images), and so can't send an
+// Authorization header. signed is responsible for checking the signature.
+func (app *application) allowSignedURL(signed http.HandlerFunc, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("sig") {
+			signed.ServeHTTP(w, r)
+			return
+		}
 
 		next.ServeHTTP(w, r)
 	})
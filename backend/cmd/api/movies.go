@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/camru/greenlight/internal/data"
+	"github.com/camru/greenlight/internal/metadata"
 	"github.com/camru/greenlight/internal/validator"
 )
 
@@ -27,17 +31,17 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 	// a subset of the Movie struct that we created earlier). This struct will
 	// be our *target decode destination*.
 	var input struct {
-		Title              string   `json:"title"`
-		DateWatched        string   `json:"dateWatched"`
-		DateWatchedSeasons []string `json:"dateWatchedSeasons"`
-		Tags               []string `json:"tags"`
-		Year               string   `json:"year,omitempty"`
-		MediaType          string   `json:"mediaType"`
-		Thumbnail          string   `json:"thumbnail"`
-		ImdbID             string   `json:"imdbID"`
-		Rating             float32  `json:"rating"`
-		Ratings            string   `json:"ratings"`
-		Watched            bool     `json:"watched"`
+		Title              string       `json:"title"`
+		DateWatched        data.Date    `json:"dateWatched"`
+		DateWatchedSeasons []data.Date  `json:"dateWatchedSeasons"`
+		Tags               []string     `json:"tags"`
+		Year               string       `json:"year,omitempty"`
+		MediaType          string       `json:"mediaType"`
+		Thumbnail          string       `json:"thumbnail"`
+		ImdbID             string       `json:"imdbID"`
+		Rating             float32      `json:"rating"`
+		Ratings            data.Ratings `json:"ratings"`
+		Watched            bool         `json:"watched"`
 	}
 
 	// Initialize a new json.Decoder instance which reads from the request body,
@@ -66,12 +70,47 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 		Rating:             input.Rating,
 		Ratings:            input.Ratings,
 		Watched:            input.Watched,
+		LibraryID:          app.contextGetLibrary(r).ID,
+	}
+
+	// For things on the to-watch list dateWatched is the date they were
+	// added, so default it to today.
+	if movie.DateWatched.IsZero() {
+		movie.DateWatched = data.Today()
 	}
 
 	// Initialize a new Validator instance.
 	v := validator.New()
 
-	data.ValidateMovie(v, movie)
+	// If the client only sent an imdbID, fill in the rest of the details from
+	// the metadata provider rather than trusting the client to supply them.
+	if movie.Title == "" && movie.ImdbID != "" {
+		if !validator.Matches(movie.ImdbID, validator.ImdbIDRX) {
+			v.AddError("imdbID", "must be a valid IMDb ID")
+			app.FailedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		err = app.enrichMovie(r.Context(), movie)
+		if err != nil {
+			switch {
+			case errors.Is(err, metadata.ErrNotFound):
+				v.AddError("imdbID", "no title could be found with this IMDb ID")
+				app.FailedValidationResponse(w, r, v.Errors)
+			default:
+				app.badGatewayResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	knownTags, err := app.knownTags(movie.LibraryID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data.ValidateMovie(v, movie, knownTags)
 
 	if !v.Valid() {
 		app.FailedValidationResponse(w, r, v.Errors)
@@ -87,6 +126,8 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	app.cachePosterInBackground(movie.ID, movie.Thumbnail)
+
 	// When sending a HTTP response, we want to include a Location header to let
 	// the client know which URL they can find the newly-created resource at. We
 	// make an empty http.Header map and then use the Set() method to add a new
@@ -97,6 +138,8 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 
 	// Write a JSON response with a 201 Created status code, the movie data in
 	// the response body, and the Location header.
+	app.setPosterURLs(r, movie)
+
 	err = app.writeJSON(w, http.StatusCreated, envelope{"media": movie}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -115,7 +158,7 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 	// need to use the errors.Is() function to check if it returns a
 	// data.ErrRecordNotFound error, in which case we send a 404 Not Found
 	// response to the client.
-	movie, err := app.models.Movies.Get(id)
+	movie, err := app.models.Movies.Get(id, app.contextGetLibrary(r).ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -126,6 +169,8 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	app.setPosterURLs(r, movie)
+
 	data := envelope{
 		"movie": movie,
 	}
@@ -148,13 +193,12 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 
 	// Fetch the existing movie record from the database, sending a 404 Not
 	// Found response to the client if we couldn't find a matching record.
-	movie, err := app.models.Movies.Get(id)
+	movie, err := app.models.Movies.Get(id, app.contextGetLibrary(r).ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
 		default:
-			fmt.Println('1')
 			app.serverErrorResponse(w, r, err)
 		}
 		return
@@ -162,10 +206,10 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 
 	// Declare an input struct to hold the expected data from the client.
 	var input struct {
-		DateWatched        *string   `json:"dateWatched"`
-		DateWatchedSeasons *[]string `json:"dateWatchedSeasons"`
-		Tags               *[]string `json:"tags"`
-		Rating             *float32  `json:"rating"`
+		DateWatched        *data.Date   `json:"dateWatched"`
+		DateWatchedSeasons *[]data.Date `json:"dateWatchedSeasons"`
+		Tags               *[]string    `json:"tags"`
+		Rating             *float32     `json:"rating"`
 	}
 
 	// Read the JSON request body data into the input struct.
@@ -203,7 +247,13 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	// Entity response if any checks fail.
 	v := validator.New()
 
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	knownTags, err := app.knownTags(movie.LibraryID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if data.ValidateMovie(v, movie, knownTags); !v.Valid() {
 		app.FailedValidationResponse(w, r, v.Errors)
 		return
 	}
@@ -213,17 +263,17 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
 			app.editConflictResponse(w, r)
-
 		default:
-			fmt.Println('2')
 			app.serverErrorResponse(w, r, err)
 		}
+		return
 	}
 
+	app.setPosterURLs(r, movie)
+
 	// Write the updated movie record in a JSON response.
-	app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
 	if err != nil {
-		fmt.Println('3')
 		app.serverErrorResponse(w, r, err)
 	}
 }
@@ -235,7 +285,7 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.models.Movies.Delete(id)
+	err = app.models.Movies.Delete(id, app.contextGetLibrary(r).ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -252,12 +302,18 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 }
+
+// movieSortSafelist holds the sort values media lists can be sorted by.
+var movieSortSafelist = []string{
+	"id", "title", "year", "dateWatched", "rating", "imdbRating", "rtRating", "metacritic", "rank", "activity",
+	"-id", "-title", "-year", "-dateWatched", "-rating", "-imdbRating", "-rtRating", "-metacritic", "-rank", "-activity",
+}
+
 func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	// To keep things consistent with our other handlers, we'll define an input
 	// struct to hold the expected values from the request query string.
 	var input struct {
-		Watched   string
-		MediaType string
+		data.MovieFilters
 		data.Filters
 	}
 
@@ -269,31 +325,142 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 
 	input.Watched = app.readString(qs, "watched", "")
 	input.MediaType = app.readString(qs, "mediaType", "")
+	input.Search = app.readString(qs, "q", "")
+	input.Tags = app.readCSV(qs, "tags", []string{})
+	input.TagsAll = app.readCSV(qs, "tags_all", []string{})
+
+	// Providers are matched by slug against the cached providers for region.
+	input.Providers = app.readCSV(qs, "provider", []string{})
+	input.Region = strings.ToUpper(app.readString(qs, "region", app.config.providers.region))
+
+	// available=mine narrows the list down to titles we can stream on the
+	// services we subscribe to.
+	available := app.readString(qs, "available", "")
+	v.Check(validator.PermittedValue(available, "", "mine"), "available", "invalid available value")
+
+	if available == "mine" {
+		subscribed, err := app.subscribedProviders(app.contextGetUser(r))
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		v.Check(len(subscribed) > 0, "available", "no subscribed providers have been set")
+		input.Available = subscribed
+	}
+
+	// Watched dates are matched against the record and each of its seasons.
+	input.WatchedFrom = app.readString(qs, "watched_from", "")
+	input.WatchedTo = app.readString(qs, "watched_to", "")
+	input.YearFrom = app.readInt(qs, "year_from", 0, v)
+	input.YearTo = app.readInt(qs, "year_to", 0, v)
+
+	// The ratings filters default to 0, which means no minimum.
+	input.MinRT = app.readFloat(qs, "min_rt", 0, v)
+	input.MinIMDb = app.readFloat(qs, "min_imdb", 0, v)
+	input.MinRating = app.readFloat(qs, "min_rating", 0, v)
 
 	// Get the page and page_size query string values as integers. Notice that
 	// we set the default page value to 1 and default page_size to 20, and that
 	// we pass the validator instance as the final argument here.
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
 
-	input.Filters.Sort = app.readString(qs, "sort", "id")
+	// Searches are sorted by relevance unless the client asks otherwise.
+	defaultSort := "id"
+	if input.Search != "" {
+		defaultSort = "-rank"
+	}
 
-	input.Filters.SortSafelist = []string{"id", "title", "year", "dateWatched", "rating", "-id", "-title", "-year", "-dateWatched", "-rating"}
+	input.Filters.Sort = app.readString(qs, "sort", defaultSort)
 
-	if !v.Valid() {
+	input.Filters.SortSafelist = movieSortSafelist
+
+	data.ValidateMovieFilters(v, input.MovieFilters)
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movies, pageMetadata, err := app.models.Movies.GetAll(app.contextGetLibrary(r).ID, input.MovieFilters, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.setPosterURLs(r, movies...)
+
+	// Send a JSON response containing the movie data and the pagination
+	// metadata.
+	err = app.writeJSON(w, http.StatusOK, envelope{"media": movies, "metadata": pageMetadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GET
+func (app *application) continueWatchingHandler(w http.ResponseWriter, r *http.Request) {
+	// This is the media list narrowed down to series that are part way
+	// through, most recently watched first. Each one includes its nextEpisode.
+	var input struct {
+		data.MovieFilters
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.MediaType = data.MediaTypeSeries
+	input.InProgress = true
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+	input.Filters.Sort = "-activity"
+	input.Filters.SortSafelist = []string{"-activity"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.FailedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	movies, err := app.models.Movies.GetAll(input.Watched, input.MediaType, input.Filters)
+	movies, pageMetadata, err := app.models.Movies.GetAll(app.contextGetLibrary(r).ID, input.MovieFilters, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	// Send a JSON response containing the movie data.
-	err = app.writeJSON(w, http.StatusOK, envelope{"media": movies}, nil)
+	app.setPosterURLs(r, movies...)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"media": movies, "metadata": pageMetadata}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// enrichMovie looks the movie up by its imdbID and copies the title, year,
+// poster, media type and ratings from the metadata provider onto it.
+func (app *application) enrichMovie(ctx context.Context, movie *data.Movie) error {
+	title, err := app.metadata.Lookup(ctx, movie.ImdbID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	movie.Title = title.Title
+	movie.Year = title.Year
+	movie.MediaType = title.Type
+	movie.Ratings = ratingsFromMetadata(title.Ratings)
+	movie.RatingsRefreshedAt = &now
+
+	// OMDb uses "N/A" when it doesn't have a poster.
+	if title.Poster != "N/A" {
+		movie.Thumbnail = title.Poster
+	}
+
+	return nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/camru/greenlight/internal/data"
+	"github.com/camru/greenlight/internal/posters"
+	"github.com/camru/greenlight/internal/validator"
+)
+
+// posterURLTTL is how long a signed poster URL is valid for, at least. URLs
+// are signed to expire at the end of the next whole period, so the same record
+// keeps the same URL (and browsers keep their cached copy) for a while.
+const posterURLTTL = 24 * time.Hour
+
+// GET
+func (app *application) showMoviePosterHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.readMovieForLibrary(w, r)
+	if !ok {
+		return
+	}
+
+	app.servePoster(w, r, movie)
+}
+
+// GET, with one of the signed URLs from setPosterURLs() in place of an
+// Authorization header.
+func (app *application) showSignedMoviePosterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	libraryID := app.readInt(qs, "library_id", 0, v)
+	expires := app.readInt(qs, "expires", 0, v)
+	sig := app.readString(qs, "sig", "")
+
+	if !v.Valid() {
+		app.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// The signature covers the record, the library and the expiry time, so
+	// none of them can be changed to get at anything else.
+	want := app.posterSignature(id, int64(libraryID), int64(expires))
+	if !hmac.Equal([]byte(sig), []byte(want)) || time.Now().Unix() > int64(expires) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id, int64(libraryID))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.servePoster(w, r, movie)
+}
+
+// servePoster sends a record's poster, resized if the w query string
+// parameter asks for it.
+func (app *application) servePoster(w http.ResponseWriter, r *http.Request, movie *data.Movie) {
+	// w asks for a smaller copy, from the allowed widths. 0 means full size.
+	v := validator.New()
+
+	width := app.readInt(r.URL.Query(), "w", 0, v)
+	if width != 0 {
+		v.Check(validator.PermittedValue(width, posters.Widths...), "w", fmt.Sprintf("must be one of %v", posters.Widths))
+	}
+
+	if !v.Valid() {
+		app.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Until the poster has been cached (or if caching is turned off, or the
+	// file has gone missing) send the client to the remote copy instead.
+	fallback := func() {
+		if !posters.IsRemote(movie.Thumbnail) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		http.Redirect(w, r, movie.Thumbnail, http.StatusFound)
+	}
+
+	if app.posters == nil || movie.PosterFile == "" {
+		fallback()
+		return
+	}
+
+	// Resized copies are made from the cached original the first time they're
+	// asked for. Posters which are already small enough come back as they are,
+	// and ones too big to resize safely are sent at full size.
+	name := movie.PosterFile
+
+	var err error
+	if width != 0 {
+		name, err = app.posters.Resized(name, width)
+		if errors.Is(err, posters.ErrTooLarge) {
+			name, err = movie.PosterFile, nil
+		}
+	}
+
+	var file *os.File
+	if err == nil {
+		file, err = app.posters.Open(name)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			fallback()
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// A record's poster only changes if it's recached, which gives it a new
+	// ETag, so browsers can hang on to it for a long time. ServeContent()
+	// answers If-None-Match with a 304 Not Modified for us.
+	w.Header().Set("Cache-Control", "private, max-age=2592000")
+	w.Header().Set("ETag", posters.ETag(name))
+
+	http.ServeContent(w, r, name, info.ModTime(), file)
+}
+
+// setPosterURLs fills in the poster URL of records which have a poster to
+// show, cached or not. The URLs are signed for the library of the request, so
+// that they work without an Authorization header.
+func (app *application) setPosterURLs(r *http.Request, movies ...*data.Movie) {
+	libraryID := app.contextGetLibrary(r).ID
+
+	period := int64(posterURLTTL / time.Second)
+	expires := (time.Now().Unix()/period + 2) * period
+
+	for _, movie := range movies {
+		if movie.PosterFile == "" && !posters.IsRemote(movie.Thumbnail) {
+			continue
+		}
+
+		movie.Poster = fmt.Sprintf("/v1/movies/%d/poster?library_id=%d&expires=%d&sig=%s",
+			movie.ID, libraryID, expires, app.posterSignature(movie.ID, libraryID, expires))
+	}
+}
+
+// posterSignature returns the signature for a poster URL, an HMAC-SHA256 of
+// its parameters keyed with the posters secret.
+func (app *application) posterSignature(id, libraryID, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(app.config.posters.secret))
+	fmt.Fprintf(mac, "%d:%d:%d", id, libraryID, expires)
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// cachePosterInBackground downloads a record's poster without holding up the
+// response. Failures are only logged, as the poster endpoint falls back to
+// the remote URL, and backfill-posters can have another go later.
+func (app *application) cachePosterInBackground(id int64, thumbnail string) {
+	if app.posters == nil || !posters.IsRemote(thumbnail) {
+		return
+	}
+
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Printf("poster cache panicked: %v", err)
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		name, err := app.posters.Fetch(ctx, thumbnail)
+		if err != nil {
+			app.logger.Printf("poster cache: %d (%s): %v", id, thumbnail, err)
+			return
+		}
+
+		err = app.models.Movies.SetPosterFile(id, name)
+		if err != nil {
+			app.logger.Printf("poster cache: %d: %v", id, err)
+		}
+	}()
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/camru/greenlight/internal/data"
+	"github.com/camru/greenlight/internal/metadata"
+	"github.com/camru/greenlight/internal/validator"
+)
+
+// GET
+func (app *application) showMovieProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.readMovieForLibrary(w, r)
+	if !ok {
+		return
+	}
+
+	region := strings.ToUpper(app.readString(r.URL.Query(), "region", app.config.providers.region))
+
+	v := validator.New()
+
+	if data.ValidateRegion(v, region); !v.Valid() {
+		app.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	providers, refreshedAt, err := app.models.Providers.GetForMedia(movie.ID, region)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Only go to TMDB if we've never asked about this title in this region, or
+	// the answer we have has gone stale. Records without an IMDb id can't be
+	// looked up at all.
+	stale := refreshedAt == nil || time.Since(*refreshedAt) > app.config.providers.maxAge
+
+	if stale && movie.ImdbID != "" {
+		fetched, err := app.fetchProviders(r.Context(), movie, region)
+		switch {
+		case err == nil:
+			now := time.Now()
+			providers, refreshedAt = fetched, &now
+		case refreshedAt != nil:
+			// An old answer is better than none, so log the failure and carry
+			// on with what we have cached.
+			app.logError(r, err)
+		default:
+			app.badGatewayResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"providers": providers, "region": region, "refreshed_at": refreshedAt}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GET
+func (app *application) showSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	subscribed, err := app.subscribedProviders(app.contextGetUser(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"providers": subscribed}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// PUT
+func (app *application) updateSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	// An empty list goes back to the server wide default.
+	var input struct {
+		Providers []string `json:"providers"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateSubscriptions(v, input.Providers); !v.Valid() {
+		app.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	err = app.models.Providers.SetSubscriptions(user.ID, input.Providers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	subscribed, err := app.subscribedProviders(user)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"providers": subscribed}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// subscribedProviders returns the slugs of the providers a user subscribes
+// to, falling back to the -providers-subscribed list if they haven't set any.
+func (app *application) subscribedProviders(user *data.User) ([]string, error) {
+	subscribed, err := app.models.Providers.GetSubscriptions(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(subscribed) == 0 && app.config.providers.subscribed != nil {
+		return app.config.providers.subscribed, nil
+	}
+
+	return subscribed, nil
+}
+
+// providersRefreshBatchSize caps how many titles are refetched per run, to
+// stay well inside TMDB's rate limits.
+const providersRefreshBatchSize = 50
+
+// startProvidersRefresher launches a goroutine which periodically refetches
+// the watch providers of to-watch entries in the default region, so that the
+// provider and available=mine filters have something to go on, and so that
+// availability changes get noticed. It stops when ctx is cancelled, and is
+// tracked by app.wg so that serve() can wait for it. Without a TMDB API key
+// (or -tmdb-fake) every refetch would fail, so the worker isn't started.
+func (app *application) startProvidersRefresher(ctx context.Context) {
+	if app.config.providers.refreshInterval <= 0 {
+		return
+	}
+
+	if app.config.tmdb.apiKey == "" {
+		app.logger.Printf("no TMDB API key set, watch providers won't be refreshed")
+		return
+	}
+
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		ticker := time.NewTicker(app.config.providers.refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			app.runRecovered("providers refresher", func() {
+				app.refreshStaleProviders(ctx)
+			})
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// refreshStaleProviders refetches one batch of stale watch providers.
+func (app *application) refreshStaleProviders(ctx context.Context) {
+	region := app.config.providers.region
+	cutoff := time.Now().Add(-app.config.providers.maxAge)
+
+	movies, err := app.models.Movies.GetStaleProviders(region, cutoff, providersRefreshBatchSize)
+	if err != nil {
+		app.logger.Printf("providers refresh: %v", err)
+		return
+	}
+
+	refreshed := 0
+
+	for _, movie := range movies {
+		// Stop part way through the batch if the server is shutting down.
+		if ctx.Err() != nil {
+			return
+		}
+
+		_, err := app.fetchProviders(ctx, movie, region)
+		if err != nil {
+			app.logger.Printf("providers refresh: %s (%s): %v", movie.Title, movie.ImdbID, err)
+			continue
+		}
+
+		refreshed++
+	}
+
+	if len(movies) > 0 {
+		app.logger.Printf("providers refresh: updated %d of %d stale titles", refreshed, len(movies))
+	}
+}
+
+// fetchProviders looks up where a title can be watched in region, and caches
+// the answer. A title TMDB doesn't know about is cached as having no providers,
+// so that we don't keep asking.
+func (app *application) fetchProviders(ctx context.Context, movie *data.Movie, region string) ([]data.Provider, error) {
+	found, err := app.watchProviders.WatchProviders(ctx, movie.ImdbID, region)
+	if err != nil && !errors.Is(err, metadata.ErrNotFound) {
+		return nil, err
+	}
+
+	providers := providersFromMetadata(found)
+
+	err = app.models.Providers.Replace(movie.ID, region, providers)
+	if err != nil {
+		return nil, err
+	}
+
+	return providers, nil
+}
+
+// providersFromMetadata converts the watch providers reported by TMDB into the
+// providers we store.
+func providersFromMetadata(providers []metadata.WatchProvider) []data.Provider {
+	converted := []data.Provider{}
+
+	for _, p := range providers {
+		converted = append(converted, data.Provider{
+			ID:       p.ID,
+			Name:     p.Name,
+			Slug:     p.Slug,
+			LogoPath: p.LogoPath,
+			Type:     p.Type,
+		})
+	}
+
+	return converted
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/camru/greenlight/internal/data"
+	"github.com/camru/greenlight/internal/metadata"
+)
+
+// ratingsRefreshBatchSize caps how many titles are refetched per run, to stay
+// well inside OMDb's daily request limit.
+const ratingsRefreshBatchSize = 50
+
+// startRatingsRefresher launches a goroutine which periodically refetches the
+// external ratings of to-watch entries that have gone stale. It stops when ctx
+// is cancelled, and is tracked by app.wg so that serve() can wait for it.
+// Without an OMDb API key (or -omdb-fake) every refetch would fail, so the
+// worker isn't started.
+func (app *application) startRatingsRefresher(ctx context.Context) {
+	if app.config.ratings.refreshInterval <= 0 {
+		return
+	}
+
+	if app.config.omdb.apiKey == "" {
+		app.logger.Printf("no OMDb API key set, ratings won't be refreshed")
+		return
+	}
+
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		ticker := time.NewTicker(app.config.ratings.refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			app.runRecovered("ratings refresher", func() {
+				app.refreshStaleRatings(ctx)
+			})
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// refreshStaleRatings refetches one batch of stale ratings.
+func (app *application) refreshStaleRatings(ctx context.Context) {
+	cutoff := time.Now().Add(-app.config.ratings.maxAge)
+
+	movies, err := app.models.Movies.GetStaleRatings(cutoff, ratingsRefreshBatchSize)
+	if err != nil {
+		app.logger.Printf("ratings refresh: %v", err)
+		return
+	}
+
+	refreshed := 0
+
+	for _, movie := range movies {
+		// Stop part way through the batch if the server is shutting down.
+		if ctx.Err() != nil {
+			return
+		}
+
+		title, err := app.metadata.Lookup(ctx, movie.ImdbID)
+		if err != nil {
+			// A title the provider no longer knows about keeps its old ratings,
+			// but still counts as refreshed so that we don't ask again every run.
+			if !errors.Is(err, metadata.ErrNotFound) {
+				app.logger.Printf("ratings refresh: %s (%s): %v", movie.Title, movie.ImdbID, err)
+				continue
+			}
+
+			current, err := app.models.Movies.Get(movie.ID, movie.LibraryID)
+			if err != nil {
+				app.logger.Printf("ratings refresh: %s (%s): %v", movie.Title, movie.ImdbID, err)
+				continue
+			}
+			movie.Ratings = current.Ratings
+		} else {
+			movie.Ratings = ratingsFromMetadata(title.Ratings)
+		}
+
+		// If somebody edited the record while we were fetching, skip it. It's
+		// still stale, so the next run will pick it up again.
+		err = app.models.Movies.UpdateRatings(movie)
+		if err != nil {
+			if !errors.Is(err, data.ErrEditConflict) {
+				app.logger.Printf("ratings refresh: %s (%s): %v", movie.Title, movie.ImdbID, err)
+			}
+			continue
+		}
+
+		refreshed++
+	}
+
+	if len(movies) > 0 {
+		app.logger.Printf("ratings refresh: updated %d of %d stale titles", refreshed, len(movies))
+	}
+}
+
+// ratingsFromMetadata converts the ratings reported by the metadata provider
+// into the ratings we store.
+func ratingsFromMetadata(ratings []metadata.Rating) data.Ratings {
+	converted := data.Ratings{}
+
+	for _, rating := range ratings {
+		converted = append(converted, data.NewRating(rating.Source, rating.Value))
+	}
+
+	return converted
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"net/http"
 
+	"github.com/camru/greenlight/internal/data"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -43,11 +44,46 @@ func (app *application) routes() http.Handler {
 	// http.MethodGet and http.MethodPost are constants which equate to the
 	// strings "GET" and "POST" respectively.
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/movies", app.createMovieHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/movies", app.listMoviesHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.showMovieHandler)
-	router.HandlerFunc(http.MethodPut, "/v1/movies/:id", app.updateMovieHandler)
-	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.deleteMovieHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/movies", app.requirePermission("media:write", app.requireLibraryRole(data.RoleEditor, app.createMovieHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/movies", app.requirePermission("media:read", app.requireLibraryRole(data.RoleViewer, app.listMoviesHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.requirePermission("media:read", app.requireLibraryRole(data.RoleViewer, app.showMovieHandler)))
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id", app.requirePermission("media:write", app.requireLibraryRole(data.RoleEditor, app.updateMovieHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.requirePermission("media:write", app.requireLibraryRole(data.RoleEditor, app.deleteMovieHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/poster", app.allowSignedURL(app.showSignedMoviePosterHandler, app.requirePermission("media:read", app.requireLibraryRole(data.RoleViewer, app.showMoviePosterHandler))))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/providers", app.requirePermission("media:read", app.requireLibraryRole(data.RoleViewer, app.showMovieProvidersHandler)))
+
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/watches", app.requirePermission("media:write", app.requireLibraryRole(data.RoleEditor, app.createWatchEventHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/watches", app.requirePermission("media:read", app.requireLibraryRole(data.RoleViewer, app.listWatchEventsHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/watches/:watchID", app.requirePermission("media:write", app.requireLibraryRole(data.RoleEditor, app.deleteWatchEventHandler)))
+
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/seasons/:n", app.requirePermission("media:read", app.requireLibraryRole(data.RoleViewer, app.showSeasonHandler)))
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/seasons/:n", app.requirePermission("media:write", app.requireLibraryRole(data.RoleEditor, app.updateSeasonHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/seasons/:n/episodes/:e/watch", app.requirePermission("media:write", app.requireLibraryRole(data.RoleEditor, app.watchEpisodeHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/continue-watching", app.requirePermission("media:read", app.requireLibraryRole(data.RoleViewer, app.continueWatchingHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/export.csv", app.requirePermission("media:read", app.requireLibraryRole(data.RoleViewer, app.exportMoviesHandler)))
+
+	router.HandlerFunc(http.MethodPost, "/v1/tags", app.requirePermission("media:write", app.requireLibraryRole(data.RoleEditor, app.createTagHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/tags", app.requirePermission("media:read", app.requireLibraryRole(data.RoleViewer, app.listTagsHandler)))
+	router.HandlerFunc(http.MethodPut, "/v1/tags/:name", app.requirePermission("media:write", app.requireLibraryRole(data.RoleEditor, app.updateTagHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/tags/:name", app.requirePermission("media:write", app.requireLibraryRole(data.RoleEditor, app.deleteTagHandler)))
+
+	router.HandlerFunc(http.MethodGet, "/v1/providers/subscriptions", app.requireAuthenticatedUser(app.showSubscriptionsHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/providers/subscriptions", app.requireAuthenticatedUser(app.updateSubscriptionsHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/lookup", app.requireAuthenticatedUser(app.searchLookupHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/lookup/:imdbID", app.requireAuthenticatedUser(app.showLookupHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+
+	router.HandlerFunc(http.MethodPost, "/v1/api-keys", app.requireAuthenticatedUser(app.createAPIKeyHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/api-keys", app.requireAuthenticatedUser(app.listAPIKeysHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/api-keys/:id", app.requireAuthenticatedUser(app.deleteAPIKeyHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/libraries", app.requirePermission("media:write", app.createLibraryHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/libraries", app.requireAuthenticatedUser(app.listLibrariesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/libraries/:id/invitations", app.requireAuthenticatedUser(app.createInvitationHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/invitations/accepted", app.requireAuthenticatedUser(app.acceptInvitationHandler))
 
 	// If you ever need to serve the static folder from the backend
 	// These map to the frontend routes handled by react-router
@@ -58,5 +94,5 @@ func (app *application) routes() http.Handler {
 	// router.NotFound = fs
 
 	// return router
-	return app.enableCORS(router)
+	return app.enableCORS(app.authenticate(router))
 }
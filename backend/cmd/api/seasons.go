@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/camru/greenlight/internal/data"
+	"github.com/camru/greenlight/internal/validator"
+)
+
+// GET
+func (app *application) showSeasonHandler(w http.ResponseWriter, r *http.Request) {
+	movie, number, ok := app.readSeriesAndSeason(w, r)
+	if !ok {
+		return
+	}
+
+	season, err := app.models.Seasons.Get(movie.ID, number)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"season": season}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// PUT
+func (app *application) updateSeasonHandler(w http.ResponseWriter, r *http.Request) {
+	movie, number, ok := app.readSeriesAndSeason(w, r)
+	if !ok {
+		return
+	}
+
+	// A PUT replaces the whole season (creating it if need be), so leaving
+	// watched_on or rating out clears them.
+	var input struct {
+		EpisodeCount int       `json:"episode_count"`
+		WatchedOn    data.Date `json:"watched_on"`
+		Rating       *float32  `json:"rating"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	season := &data.Season{
+		MediaID:      movie.ID,
+		Number:       number,
+		EpisodeCount: input.EpisodeCount,
+		WatchedOn:    input.WatchedOn,
+		Rating:       input.Rating,
+	}
+
+	v := validator.New()
+
+	if data.ValidateSeason(v, season); !v.Valid() {
+		app.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Seasons.Put(season)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Read the season back so the response includes its episodes.
+	season, err = app.models.Seasons.Get(movie.ID, number)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"season": season}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// POST
+func (app *application) watchEpisodeHandler(w http.ResponseWriter, r *http.Request) {
+	movie, number, ok := app.readSeriesAndSeason(w, r)
+	if !ok {
+		return
+	}
+
+	episode, err := app.readNamedIdParam(r, "e")
+	if err != nil || episode > 500 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// The body is optional, and watched_on defaults to today.
+	var input struct {
+		WatchedOn data.Date `json:"watched_on"`
+	}
+
+	if r.ContentLength != 0 {
+		err = app.readJSON(w, r, &input)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	if input.WatchedOn.IsZero() {
+		input.WatchedOn = data.Today()
+	}
+
+	v := validator.New()
+
+	if v.Check(!input.WatchedOn.After(data.Today().Time), "watched_on", "must not be in the future"); !v.Valid() {
+		app.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Seasons.WatchEpisode(movie.ID, number, int(episode), input.WatchedOn)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	season, err := app.models.Seasons.Get(movie.ID, number)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"season": season}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readSeriesAndSeason reads the :id and :n parameters of a season route. Only
+// series have seasons, so any other kind of record is reported as not found.
+// If something goes wrong it sends the error response itself and returns
+// false.
+func (app *application) readSeriesAndSeason(w http.ResponseWriter, r *http.Request) (*data.Movie, int, bool) {
+	movie, ok := app.readMovieForLibrary(w, r)
+	if !ok {
+		return nil, 0, false
+	}
+
+	number, err := app.readNamedIdParam(r, "n")
+	if err != nil || number > 100 || movie.MediaType != data.MediaTypeSeries {
+		app.notFoundResponse(w, r)
+		return nil, 0, false
+	}
+
+	return movie, int(number), true
+}
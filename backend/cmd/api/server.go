@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func (app *application) serve() error {
+	// Declare a HTTP server with some sensible timeout settings,
+	// which listens on the port provided in the config struct and uses the
+	// servemux we created above as the handler.
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", app.config.port),
+		Handler:      app.routes(),
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+
+	// Create a context for the background workers. Cancelling it tells them to
+	// finish what they're doing and return.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	app.startRatingsRefresher(workerCtx)
+	app.startProvidersRefresher(workerCtx)
+
+	// Create a shutdownError channel. We will use this to receive any errors
+	// returned by the graceful Shutdown() function.
+	shutdownError := make(chan error)
+
+	go func() {
+		// Wait for a SIGINT or SIGTERM signal.
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		app.logger.Printf("shutting down server, signal: %s", s.String())
+
+		// Create a context with a 20-second timeout.
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+
+		// Call Shutdown() on the server, which stops accepting new requests and
+		// waits for the in-flight ones to finish.
+		err := srv.Shutdown(ctx)
+
+		// Stop the background workers and wait for them to complete, so that
+		// nothing is left writing to the database when main() closes it.
+		app.logger.Printf("completing background tasks on %s", srv.Addr)
+
+		stopWorkers()
+		app.wg.Wait()
+
+		shutdownError <- err
+	}()
+
+	app.logger.Printf("starting %s server on %s", app.config.env, srv.Addr)
+
+	// Calling Shutdown() on our server will cause ListenAndServe() to immediately
+	// return a http.ErrServerClosed error. So if we see this error, it is actually a
+	// good thing and an indication that the graceful shutdown has started. So we check
+	// specifically for this, only returning the error if it is NOT http.ErrServerClosed.
+	err := srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	// Otherwise, we wait to receive the return value from Shutdown() on the
+	// shutdownError channel. If return value is an error, we know that there was a
+	// problem with the graceful shutdown and we return the error.
+	err = <-shutdownError
+	if err != nil {
+		return err
+	}
+
+	// At this point we know that the graceful shutdown completed successfully and we
+	// log a "stopped server" message.
+	app.logger.Printf("stopped server on %s", srv.Addr)
+
+	return nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/camru/greenlight/internal/data"
+	"github.com/camru/greenlight/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// knownTags returns the tags media records in the library are allowed to use,
+// or nil if any tag is allowed because unknown ones get created.
+func (app *application) knownTags(libraryID int64) ([]string, error) {
+	if app.config.tags.autoCreate {
+		return nil, nil
+	}
+
+	return app.models.Tags.GetNamesForLibrary(libraryID)
+}
+
+// POST
+func (app *application) createTagHandler(w http.ResponseWriter, r *http.Request) {
+	// The display name defaults to the name, and the season is optional.
+	var input struct {
+		Name        string  `json:"name"`
+		DisplayName string  `json:"display_name"`
+		Color       string  `json:"color"`
+		Icon        string  `json:"icon"`
+		SeasonStart *string `json:"season_start"`
+		SeasonEnd   *string `json:"season_end"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	tag := &data.Tag{
+		LibraryID:   app.contextGetLibrary(r).ID,
+		Name:        input.Name,
+		DisplayName: input.DisplayName,
+		Color:       input.Color,
+		Icon:        input.Icon,
+		SeasonStart: input.SeasonStart,
+		SeasonEnd:   input.SeasonEnd,
+	}
+
+	if tag.DisplayName == "" {
+		tag.DisplayName = tag.Name
+	}
+
+	v := validator.New()
+
+	if data.ValidateTag(v, tag); !v.Valid() {
+		app.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Tags.Insert(tag)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateTag):
+			v.AddError("name", "a tag with this name already exists")
+			app.FailedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/tags/%s", tag.Name))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"tag": tag}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GET
+func (app *application) listTagsHandler(w http.ResponseWriter, r *http.Request) {
+	// seasonal=current narrows the list down to tags whose season includes
+	// today.
+	seasonal := app.readString(r.URL.Query(), "seasonal", "")
+
+	v := validator.New()
+
+	if v.Check(validator.PermittedValue(seasonal, "", "current"), "seasonal", "must be current"); !v.Valid() {
+		app.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	tags, err := app.models.Tags.GetAllForLibrary(app.contextGetLibrary(r).ID, seasonal == "current")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"tags": tags}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// PUT
+func (app *application) updateTagHandler(w http.ResponseWriter, r *http.Request) {
+	name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+
+	tag, err := app.models.Tags.Get(app.contextGetLibrary(r).ID, name)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Only the fields that are sent are changed. Changing the name renames the
+	// tag on every record that has it, and an empty season_start and
+	// season_end removes the season.
+	var input struct {
+		Name        *string `json:"name"`
+		DisplayName *string `json:"display_name"`
+		Color       *string `json:"color"`
+		Icon        *string `json:"icon"`
+		SeasonStart *string `json:"season_start"`
+		SeasonEnd   *string `json:"season_end"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		tag.Name = *input.Name
+	}
+	if input.DisplayName != nil {
+		tag.DisplayName = *input.DisplayName
+	}
+	if input.Color != nil {
+		tag.Color = *input.Color
+	}
+	if input.Icon != nil {
+		tag.Icon = *input.Icon
+	}
+	if input.SeasonStart != nil {
+		tag.SeasonStart = input.SeasonStart
+		if *input.SeasonStart == "" {
+			tag.SeasonStart = nil
+		}
+	}
+	if input.SeasonEnd != nil {
+		tag.SeasonEnd = input.SeasonEnd
+		if *input.SeasonEnd == "" {
+			tag.SeasonEnd = nil
+		}
+	}
+
+	v := validator.New()
+
+	if data.ValidateTag(v, tag); !v.Valid() {
+		app.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Tags.Update(tag, name)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateTag):
+			v.AddError("name", "a tag with this name already exists")
+			app.FailedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"tag": tag}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// DELETE
+func (app *application) deleteTagHandler(w http.ResponseWriter, r *http.Request) {
+	name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+
+	err := app.models.Tags.Delete(app.contextGetLibrary(r).ID, name)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": fmt.Sprintf("tag %q successfully deleted", name)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/camru/greenlight/internal/data"
+	"github.com/camru/greenlight/internal/validator"
+)
+
+// POST
+func (app *application) createWatchEventHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.readMovieForLibrary(w, r)
+	if !ok {
+		return
+	}
+
+	// watched_on defaults to today.
+	var input struct {
+		WatchedOn   data.Date `json:"watched_on"`
+		Rating      *float32  `json:"rating"`
+		Note        string    `json:"note"`
+		WatchedWith []string  `json:"watched_with"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	event := &data.WatchEvent{
+		MediaID:     movie.ID,
+		WatchedOn:   input.WatchedOn,
+		Rating:      input.Rating,
+		Note:        input.Note,
+		WatchedWith: input.WatchedWith,
+	}
+
+	if event.WatchedOn.IsZero() {
+		event.WatchedOn = data.Today()
+	}
+
+	v := validator.New()
+
+	if data.ValidateWatchEvent(v, event); !v.Valid() {
+		app.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.WatchEvents.Insert(event)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/movies/%d/watches/%d", movie.ID, event.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"watch": event}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GET
+func (app *application) listWatchEventsHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.readMovieForLibrary(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := app.models.WatchEvents.GetAllForMedia(movie.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"watches": events}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// DELETE
+func (app *application) deleteWatchEventHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.readMovieForLibrary(w, r)
+	if !ok {
+		return
+	}
+
+	watchID, err := app.readNamedIdParam(r, "watchID")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.WatchEvents.Delete(watchID, movie.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": fmt.Sprintf("watch with id %v successfully deleted", watchID)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
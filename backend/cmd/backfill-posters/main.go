@@ -0,0 +1,97 @@
+// Command backfill-posters downloads the posters of existing media records
+// into the poster cache, for records saved before posters were cached or
+// whose download failed at the time.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/camru/greenlight/internal/data"
+	"github.com/camru/greenlight/internal/posters"
+	_ "github.com/lib/pq"
+)
+
+// batchSize is how many records are read from the database at a time.
+const batchSize = 100
+
+func main() {
+	var (
+		dsn   string
+		dir   string
+		delay time.Duration
+	)
+
+	flag.StringVar(&dsn, "db-dsn", os.Getenv("GREENLIGHT_DB_DSN"), "PostgreSQL DSN")
+	flag.StringVar(&dir, "posters-dir", "posters", "Directory to cache posters in")
+	flag.DurationVar(&delay, "delay", 200*time.Millisecond, "Pause between downloads, to go easy on the image hosts")
+	flag.Parse()
+
+	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	models := data.NewModels(db)
+	cache := posters.New(dir)
+
+	var afterID int64
+	cached, failed := 0, 0
+
+	for {
+		movies, err := models.Movies.GetMissingPosters(afterID, batchSize)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		if len(movies) == 0 {
+			break
+		}
+
+		for _, movie := range movies {
+			afterID = movie.ID
+
+			// A failed download is logged and skipped. Running the command
+			// again will retry it.
+			err := backfill(models, cache, movie)
+			if err != nil {
+				logger.Printf("%d %s (%s): %v", movie.ID, movie.Title, movie.Thumbnail, err)
+				failed++
+			} else {
+				cached++
+			}
+
+			time.Sleep(delay)
+		}
+	}
+
+	logger.Printf("cached %d posters, %d failed", cached, failed)
+}
+
+// backfill downloads one record's poster and records where it was saved.
+func backfill(models data.Models, cache *posters.Cache, movie *data.Movie) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	name, err := cache.Fetch(ctx, movie.Thumbnail)
+	if err != nil {
+		return err
+	}
+
+	return models.Movies.SetPosterFile(movie.ID, name)
+}
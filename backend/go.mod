@@ -5,4 +5,5 @@ go 1.19
 require (
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/lib/pq v1.10.2
+	golang.org/x/crypto v0.14.0
 )
@@ -0,0 +1,176 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/camru/greenlight/internal/validator"
+)
+
+// An APIKey is a long-lived credential for scripts and shortcuts. The plaintext
+// key is only ever returned when the key is created. After that the prefix is
+// all that's shown, so people can tell their keys apart.
+type APIKey struct {
+	ID        int64      `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    int64      `json:"-"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Plaintext string     `json:"key,omitempty"`
+	Hash      []byte     `json:"-"`
+	Expiry    *time.Time `json:"expiry"`
+}
+
+// generateAPIKey creates a new key in the format "<prefix>.<secret>". The prefix
+// is 8 characters, and the secret is the same size as an authentication token.
+func generateAPIKey(userID int64, name string, expiry *time.Time) (*APIKey, error) {
+	randomBytes := make([]byte, 21)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+
+	key := &APIKey{
+		UserID: userID,
+		Name:   name,
+		Prefix: encoded[:8],
+		Expiry: expiry,
+	}
+
+	key.Plaintext = key.Prefix + "." + encoded[8:]
+
+	hash := sha256.Sum256([]byte(key.Plaintext))
+	key.Hash = hash[:]
+
+	return key, nil
+}
+
+func ValidateAPIKey(v *validator.Validator, key *APIKey) {
+	v.Check(key.Name != "", "name", "must be provided")
+	v.Check(len(key.Name) <= 100, "name", "must not be more than 100 bytes long")
+
+	if key.Expiry != nil {
+		v.Check(key.Expiry.After(time.Now()), "expiry", "must be in the future")
+	}
+}
+
+// Check that the plaintext key looks like something generateAPIKey() made.
+func ValidateAPIKeyPlaintext(v *validator.Validator, keyPlaintext string) {
+	prefix, secret, found := strings.Cut(keyPlaintext, ".")
+
+	v.Check(found, "key", "must be in the format <prefix>.<secret>")
+	v.Check(len(prefix) == 8 && len(secret) == 26, "key", "must be 35 bytes long")
+}
+
+// Define the APIKeyModel type.
+type APIKeyModel struct {
+	DB *sql.DB
+}
+
+// The New() method generates a new key for the user and stores it. The returned
+// APIKey is the only one that will ever have its Plaintext field set.
+func (m APIKeyModel) New(userID int64, name string, expiry *time.Time) (*APIKey, error) {
+	key, err := generateAPIKey(userID, name, expiry)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	INSERT INTO api_keys (user_id, name, prefix, hash, expiry)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at`
+
+	args := []any{key.UserID, key.Name, key.Prefix, key.Hash, key.Expiry}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// GetAllForUser returns every key the user has created, newest first.
+func (m APIKeyModel) GetAllForUser(userID int64) ([]*APIKey, error) {
+	query := `
+	SELECT id, created_at, user_id, name, prefix, expiry
+	FROM api_keys
+	WHERE user_id = $1
+	ORDER BY id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*APIKey{}
+
+	for rows.Next() {
+		var key APIKey
+
+		err := rows.Scan(
+			&key.ID,
+			&key.CreatedAt,
+			&key.UserID,
+			&key.Name,
+			&key.Prefix,
+			&key.Expiry,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, &key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Delete revokes one of the user's keys.
+func (m APIKeyModel) Delete(id int64, userID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+	DELETE FROM api_keys
+	WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package data
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Define an error that our UnmarshalJSON() method can return if the JSON
+// string isn't a date we understand.
+var ErrInvalidDateFormat = errors.New("invalid date format: must be YYYY-MM-DD")
+
+// Date is a calendar date with no time of day, like the dates things were
+// watched on. It's stored in a PostgreSQL date column and appears in JSON as
+// "YYYY-MM-DD". The zero Date means no date, and appears as null.
+type Date struct {
+	time.Time
+}
+
+// NewDate returns the Date that t falls on, in t's own time zone.
+func NewDate(t time.Time) Date {
+	return Date{time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)}
+}
+
+// Today returns the current date in UTC.
+func Today() Date {
+	return NewDate(time.Now().UTC())
+}
+
+// ParseDate reads a date in the "YYYY-MM-DD" format. For backwards
+// compatibility it also accepts an RFC 3339 timestamp (which is what
+// JavaScript's toISOString() produces) and keeps just the date part.
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(dateLayout, s)
+	if err == nil {
+		return NewDate(t), nil
+	}
+
+	t, err = time.Parse(time.RFC3339, s)
+	if err == nil {
+		return NewDate(t), nil
+	}
+
+	return Date{}, ErrInvalidDateFormat
+}
+
+// String returns the date in the "YYYY-MM-DD" format.
+func (d Date) String() string {
+	return d.Format(dateLayout)
+}
+
+// Implement a MarshalJSON() method on the Date type so that it satisfies the
+// json.Marshaler interface.
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// Implement a UnmarshalJSON() method on the Date type so that it satisfies the
+// json.Unmarshaler interface. As with Runtime, this needs a pointer receiver
+// so that it modifies the Date itself rather than a copy.
+func (d *Date) UnmarshalJSON(jsonValue []byte) error {
+	if string(jsonValue) == "null" {
+		*d = Date{}
+		return nil
+	}
+
+	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
+	if err != nil {
+		return ErrInvalidDateFormat
+	}
+
+	date, err := ParseDate(unquotedJSONValue)
+	if err != nil {
+		return err
+	}
+
+	*d = date
+
+	return nil
+}
+
+// Scan implements the sql.Scanner interface. The driver gives us a time.Time
+// for a date column, but the elements of a date[] array arrive as text.
+func (d *Date) Scan(src any) error {
+	switch src := src.(type) {
+	case time.Time:
+		*d = NewDate(src)
+		return nil
+	case []byte:
+		date, err := ParseDate(string(src))
+		*d = date
+		return err
+	case string:
+		date, err := ParseDate(src)
+		*d = date
+		return err
+	case nil:
+		*d = Date{}
+		return nil
+	default:
+		return errors.New("unsupported type for date")
+	}
+}
+
+// Value implements the driver.Valuer interface, storing the zero Date as NULL.
+func (d Date) Value() (driver.Value, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+
+	return d.String(), nil
+}
@@ -0,0 +1,70 @@
+package data
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    string
+		wantErr error
+	}{
+		{"date", "2022-12-25", "2022-12-25", nil},
+		{"leap day", "2024-02-29", "2024-02-29", nil},
+		{"utc timestamp", "2022-12-25T18:30:00.000Z", "2022-12-25", nil},
+		{"timestamp keeps its own day", "2022-12-25T23:30:00-05:00", "2022-12-25", nil},
+		{"timestamp just after midnight", "2022-12-26T00:15:00+01:00", "2022-12-26", nil},
+		{"not a leap year", "2023-02-29", "", ErrInvalidDateFormat},
+		{"wrong order", "25-12-2022", "", ErrInvalidDateFormat},
+		{"slashes", "2022/12/25", "", ErrInvalidDateFormat},
+		{"empty", "", "", ErrInvalidDateFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDate(tt.s)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseDate(%q) error = %v; want %v", tt.s, err, tt.wantErr)
+			}
+
+			if err == nil && got.String() != tt.want {
+				t.Errorf("ParseDate(%q) = %s; want %s", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDateJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"date", `"2022-12-25"`, `"2022-12-25"`},
+		{"timestamp", `"2022-12-25T18:30:00.000Z"`, `"2022-12-25"`},
+		{"null", `null`, `null`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Date
+
+			err := d.UnmarshalJSON([]byte(tt.json))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, err := d.MarshalJSON()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("got %s; want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,12 +1,110 @@
 package data
 
-import "strings"
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/camru/greenlight/internal/validator"
+)
 
 type Filters struct {
 	Page         int
 	PageSize     int
 	Sort         string
 	SortSafelist []string
+	// Cursor is an opaque keyset pagination cursor, as returned in
+	// Metadata.NextCursor. When it is set the results start after the record
+	// it points at, instead of at Page.
+	Cursor string
+}
+
+func ValidateFilters(v *validator.Validator, f Filters) {
+	// Check that the page and page_size parameters contain sensible values.
+	v.Check(f.Page > 0, "page", "must be greater than zero")
+	v.Check(f.Page <= 10_000_000, "page", "must be a maximum of 10 million")
+	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
+	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
+
+	// Check that the sort parameter matches a value in the safelist.
+	v.Check(validator.PermittedValue(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+
+	// A cursor only makes sense for the sort order it was issued for, and it
+	// replaces the page parameter rather than adding to it.
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		v.Check(err == nil, "cursor", "invalid cursor")
+		v.Check(err != nil || validCursorValue(c.Value, movieSortTypes[strings.TrimPrefix(c.Sort, "-")]), "cursor", "invalid cursor")
+		v.Check(err != nil || c.Sort == f.Sort, "cursor", "must be used with the sort it was issued for")
+		v.Check(f.Page == 1, "cursor", "cannot be used together with page")
+	}
+}
+
+// A cursor records the sort order and the position of the last record on a
+// page: the value of its sort column (as text) and its id, which breaks ties.
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+// encodeCursor turns a cursor into the opaque string we hand to clients.
+func encodeCursor(c cursor) string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+// numberRX matches the way PostgreSQL writes numeric and real values as text.
+var numberRX = regexp.MustCompile(`^-?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$`)
+
+// timestamptzLayouts are the ways PostgreSQL writes a timestamptz as text,
+// depending on the session time zone's offset.
+var timestamptzLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07",
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999-07:00:00",
+}
+
+// validCursorValue reports whether a cursor's sort value can be cast back to
+// the SQL type of its sort column. Cursors come back from clients, so without
+// this a tampered or corrupted one would make the query fail.
+func validCursorValue(value, sqlType string) bool {
+	switch sqlType {
+	case "bigint":
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	case "date":
+		_, err := time.Parse(dateLayout, value)
+		return err == nil
+	case "numeric", "real":
+		return numberRX.MatchString(value)
+	case "timestamptz":
+		for _, layout := range timestamptzLayouts {
+			if _, err := time.Parse(layout, value); err == nil {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// decodeCursor is the reverse of encodeCursor().
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+
+	err = json.Unmarshal(js, &c)
+	return c, err
 }
 
 // Check that the client-provided Sort field matches one of the entries in our
@@ -31,3 +129,43 @@ func (f Filters) sortDirection() string {
 
 	return "ASC"
 }
+
+func (f Filters) limit() int {
+	return f.PageSize
+}
+
+func (f Filters) offset() int {
+	return (f.Page - 1) * f.PageSize
+}
+
+// Define a new Metadata struct for holding the pagination metadata.
+type Metadata struct {
+	CurrentPage  int `json:"current_page,omitempty"`
+	PageSize     int `json:"page_size,omitempty"`
+	FirstPage    int `json:"first_page,omitempty"`
+	LastPage     int `json:"last_page,omitempty"`
+	TotalRecords int `json:"total_records,omitempty"`
+	// NextCursor is set when there are more records after this page. Passing
+	// it back as the cursor parameter fetches them.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// The calculateMetadata() function calculates the appropriate pagination metadata
+// values given the total number of records, current page, and page size values. Note
+// that the last page value is calculated using the math.Ceil() function, which rounds
+// up a float to the nearest integer. So, for example, if there were 12 records in total
+// and a page size of 5, the last page value would be math.Ceil(12/5) = 3.
+func calculateMetadata(totalRecords, page, pageSize int) Metadata {
+	if totalRecords == 0 {
+		// Note that we return an empty Metadata struct if there are no records.
+		return Metadata{}
+	}
+
+	return Metadata{
+		CurrentPage:  page,
+		PageSize:     pageSize,
+		FirstPage:    1,
+		LastPage:     int(math.Ceil(float64(totalRecords) / float64(pageSize))),
+		TotalRecords: totalRecords,
+	}
+}
@@ -0,0 +1,119 @@
+package data
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/camru/greenlight/internal/validator"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		c    cursor
+	}{
+		{"title", cursor{Sort: "title", Value: "Elf", ID: 3}},
+		{"descending", cursor{Sort: "-dateWatched", Value: "2022-12-25", ID: 42}},
+		{"null sort value", cursor{Sort: "year", Value: "", ID: 7}},
+		{"awkward characters", cursor{Sort: "title", Value: `Dash & Lily "/?=`, ID: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(encodeCursor(tt.c))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.c {
+				t.Errorf("got %+v; want %+v", got, tt.c)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+	}{
+		{"not base64", "not a cursor!"},
+		{"not json", base64.RawURLEncoding.EncodeToString([]byte("not json"))},
+		{"wrong types", base64.RawURLEncoding.EncodeToString([]byte(`{"s":1,"v":"x","id":"y"}`))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeCursor(tt.s)
+			if err == nil {
+				t.Errorf("decodeCursor(%q) succeeded; want an error", tt.s)
+			}
+		})
+	}
+}
+
+func TestValidateFiltersCursor(t *testing.T) {
+	safelist := []string{"id", "title", "-title"}
+
+	tests := []struct {
+		name    string
+		filters Filters
+		valid   bool
+	}{
+		{"no cursor", Filters{Page: 2, PageSize: 20, Sort: "title"}, true},
+		{"matching sort", Filters{Page: 1, PageSize: 20, Sort: "title", Cursor: encodeCursor(cursor{Sort: "title", Value: "Elf", ID: 3})}, true},
+		{"different sort", Filters{Page: 1, PageSize: 20, Sort: "-title", Cursor: encodeCursor(cursor{Sort: "title", Value: "Elf", ID: 3})}, false},
+		{"with page", Filters{Page: 2, PageSize: 20, Sort: "title", Cursor: encodeCursor(cursor{Sort: "title", Value: "Elf", ID: 3})}, false},
+		{"garbage", Filters{Page: 1, PageSize: 20, Sort: "title", Cursor: "garbage!"}, false},
+		{"value of the wrong type", Filters{Page: 1, PageSize: 20, Sort: "id", Cursor: encodeCursor(cursor{Sort: "id", Value: "abc", ID: 3})}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filters.SortSafelist = safelist
+
+			v := validator.New()
+			ValidateFilters(v, tt.filters)
+
+			if v.Valid() != tt.valid {
+				t.Errorf("got valid %t; want %t (errors: %v)", v.Valid(), tt.valid, v.Errors)
+			}
+		})
+	}
+}
+
+func TestValidCursorValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		sqlType string
+		want    bool
+	}{
+		{"bigint", "42", "bigint", true},
+		{"bigint not a number", "abc", "bigint", false},
+		{"bigint fraction", "4.2", "bigint", false},
+		{"text", "anything at all", "text", true},
+		{"date", "2022-12-25", "date", true},
+		{"date not a date", "abc", "date", false},
+		{"date out of range", "2022-13-45", "date", false},
+		{"numeric", "85.50", "numeric", true},
+		{"numeric negative", "-1", "numeric", true},
+		{"numeric not a number", "abc", "numeric", false},
+		{"numeric go syntax", "0x1p-2", "numeric", false},
+		{"real", "0.0607927", "real", true},
+		{"real exponent", "1e-20", "real", true},
+		{"real empty", "", "real", false},
+		{"timestamptz", "2022-12-25 18:30:00.123456+00", "timestamptz", true},
+		{"timestamptz whole seconds", "2022-12-25 18:30:00-05", "timestamptz", true},
+		{"timestamptz half hour offset", "2022-12-25 18:30:00+05:30", "timestamptz", true},
+		{"timestamptz not a time", "yesterday", "timestamptz", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validCursorValue(tt.value, tt.sqlType); got != tt.want {
+				t.Errorf("validCursorValue(%q, %q) = %t; want %t", tt.value, tt.sqlType, got, tt.want)
+			}
+		})
+	}
+}
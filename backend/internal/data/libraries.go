@@ -0,0 +1,333 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/camru/greenlight/internal/validator"
+)
+
+// Define the roles a user can hold in a library. Owners can do everything,
+// editors can add and change media, and viewers can only look.
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// Rank the roles so that we can check whether a member's role is at least as
+// powerful as the one an action requires.
+var roleRanks = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleOwner:  3,
+}
+
+// A Library owns media records and is shared by one or more members. Role holds
+// the role of the user the library was fetched for.
+type Library struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role,omitempty"`
+	Version   int32     `json:"version"`
+}
+
+// Allows reports whether the current member's role is at least the given role.
+func (l *Library) Allows(role string) bool {
+	return roleRanks[l.Role] >= roleRanks[role]
+}
+
+func ValidateLibrary(v *validator.Validator, library *Library) {
+	v.Check(library.Name != "", "name", "must be provided")
+	v.Check(len(library.Name) <= 500, "name", "must not be more than 500 bytes long")
+}
+
+// An Invitation lets the holder of the plaintext token join a library, as long
+// as they are logged in with the email address it was sent to.
+type Invitation struct {
+	Plaintext string    `json:"token"`
+	Hash      []byte    `json:"-"`
+	LibraryID int64     `json:"library_id"`
+	InvitedBy int64     `json:"-"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+func ValidateInvitation(v *validator.Validator, invitation *Invitation) {
+	ValidateEmail(v, invitation.Email)
+	v.Check(validator.PermittedValue(invitation.Role, RoleOwner, RoleEditor, RoleViewer), "role", "must be owner, editor or viewer")
+}
+
+// Define a LibraryModel struct type which wraps a sql.DB connection pool.
+type LibraryModel struct {
+	DB *sql.DB
+}
+
+// Insert creates a new library and makes the given user its owner, in a single
+// transaction so that we never end up with a library nobody can reach.
+func (m LibraryModel) Insert(library *Library, ownerID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = insertLibrary(ctx, tx, library, ownerID, false)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertLibrary creates a library owned by ownerID inside the caller's
+// transaction. A personal library is also recorded as the owner's, which is
+// the one the /v1/movies endpoints act on when no library_id is given.
+func insertLibrary(ctx context.Context, tx *sql.Tx, library *Library, ownerID int64, personal bool) error {
+	query := `
+	INSERT INTO libraries (name)
+	VALUES ($1)
+	RETURNING id, created_at, version`
+
+	err := tx.QueryRowContext(ctx, query, library.Name).Scan(&library.ID, &library.CreatedAt, &library.Version)
+	if err != nil {
+		return err
+	}
+
+	query = `
+	INSERT INTO library_members (library_id, user_id, role)
+	VALUES ($1, $2, $3)`
+
+	_, err = tx.ExecContext(ctx, query, library.ID, ownerID, RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	err = insertDefaultTags(ctx, tx, library.ID)
+	if err != nil {
+		return err
+	}
+
+	if personal {
+		_, err = tx.ExecContext(ctx, `UPDATE users SET personal_library_id = $1 WHERE id = $2`, library.ID, ownerID)
+		if err != nil {
+			return err
+		}
+	}
+
+	library.Role = RoleOwner
+
+	return nil
+}
+
+// GetForUser returns a library along with the user's role in it. Libraries the
+// user isn't a member of are reported as ErrRecordNotFound.
+func (m LibraryModel) GetForUser(id int64, userID int64) (*Library, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+	SELECT libraries.id, libraries.created_at, libraries.name, library_members.role, libraries.version
+	FROM libraries
+	INNER JOIN library_members ON library_members.library_id = libraries.id
+	WHERE libraries.id = $1 AND library_members.user_id = $2`
+
+	var library Library
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, userID).Scan(
+		&library.ID,
+		&library.CreatedAt,
+		&library.Name,
+		&library.Role,
+		&library.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &library, nil
+}
+
+// GetPersonalForUser returns the user's personal library, the one created
+// when they registered, along with their role in it.
+func (m LibraryModel) GetPersonalForUser(userID int64) (*Library, error) {
+	query := `
+	SELECT libraries.id, libraries.created_at, libraries.name, library_members.role, libraries.version
+	FROM users
+	INNER JOIN libraries ON libraries.id = users.personal_library_id
+	INNER JOIN library_members ON library_members.library_id = libraries.id AND library_members.user_id = users.id
+	WHERE users.id = $1`
+
+	var library Library
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(
+		&library.ID,
+		&library.CreatedAt,
+		&library.Name,
+		&library.Role,
+		&library.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &library, nil
+}
+
+// GetAllForUser returns every library the user is a member of.
+func (m LibraryModel) GetAllForUser(userID int64) ([]*Library, error) {
+	query := `
+	SELECT libraries.id, libraries.created_at, libraries.name, library_members.role, libraries.version
+	FROM libraries
+	INNER JOIN library_members ON library_members.library_id = libraries.id
+	WHERE library_members.user_id = $1
+	ORDER BY libraries.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	libraries := []*Library{}
+
+	for rows.Next() {
+		var library Library
+
+		err := rows.Scan(
+			&library.ID,
+			&library.CreatedAt,
+			&library.Name,
+			&library.Role,
+			&library.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		libraries = append(libraries, &library)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return libraries, nil
+}
+
+// NewInvitation generates an invitation token for the given library and stores
+// its hash, returning the invitation with the plaintext token filled in.
+func (m LibraryModel) NewInvitation(invitation *Invitation, ttl time.Duration) error {
+	token, err := generateToken(invitation.InvitedBy, ttl, ScopeInvitation)
+	if err != nil {
+		return err
+	}
+
+	invitation.Plaintext = token.Plaintext
+	invitation.Hash = token.Hash
+	invitation.Expiry = token.Expiry
+
+	query := `
+	INSERT INTO library_invitations (hash, library_id, invited_by, email, role, expiry)
+	VALUES ($1, $2, $3, $4, $5, $6)`
+
+	args := []any{invitation.Hash, invitation.LibraryID, invitation.InvitedBy, invitation.Email, invitation.Role, invitation.Expiry}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// AcceptInvitation adds the user to the library the invitation is for and then
+// deletes the invitation. The invitation must not have expired and must have
+// been sent to the user's email address. If the user is already a member, the
+// more powerful of the two roles wins. Owners and editors are also granted
+// media:write, which they need to make use of their role.
+func (m LibraryModel) AcceptInvitation(tokenPlaintext string, user *User) (*Library, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	DELETE FROM library_invitations
+	WHERE hash = $1 AND email = $2 AND expiry > $3
+	RETURNING library_id, role`
+
+	var invitation Invitation
+
+	err = tx.QueryRowContext(ctx, query, tokenHash[:], user.Email, time.Now()).Scan(&invitation.LibraryID, &invitation.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	query = `
+	INSERT INTO library_members (library_id, user_id, role)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (library_id, user_id) DO UPDATE
+	SET role = CASE
+		WHEN library_members.role = 'owner' OR EXCLUDED.role = 'owner' THEN 'owner'
+		WHEN library_members.role = 'editor' OR EXCLUDED.role = 'editor' THEN 'editor'
+		ELSE 'viewer'
+	END`
+
+	_, err = tx.ExecContext(ctx, query, invitation.LibraryID, user.ID, invitation.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	if invitation.Role != RoleViewer {
+		err = addPermissionsForUser(ctx, tx, user.ID, PermissionMediaWrite)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return m.GetForUser(invitation.LibraryID, user.ID)
+}
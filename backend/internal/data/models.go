@@ -1,8 +1,10 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"time"
 )
 
 // Define a custom ErrRecordNotFound error. We'll return this from our Get()
@@ -15,13 +17,96 @@ var (
 // Create a Models struct which wraps the MovieModel. We'll add other models to
 // this, like a UserModel and PermissionModel, as our build progresses.
 type Models struct {
-	Movies MovieModel
+	APIKeys     APIKeyModel
+	Libraries   LibraryModel
+	Movies      MovieModel
+	Permissions PermissionModel
+	Providers   ProviderModel
+	Seasons     SeasonModel
+	Tags        TagModel
+	Tokens      TokenModel
+	Users       UserModel
+	WatchEvents WatchEventModel
+	// db is for work that spans several models, like RegisterUser().
+	db *sql.DB
 }
 
 // For ease of use, we also add a New() method which returns a Models struct
 // containing the initialized MovieModel.
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Movies: MovieModel{DB: db},
+		APIKeys:     APIKeyModel{DB: db},
+		Libraries:   LibraryModel{DB: db},
+		Movies:      MovieModel{DB: db},
+		Permissions: PermissionModel{DB: db},
+		Providers:   ProviderModel{DB: db},
+		Seasons:     SeasonModel{DB: db},
+		Tags:        TagModel{DB: db},
+		Tokens:      TokenModel{DB: db},
+		Users:       UserModel{DB: db},
+		WatchEvents: WatchEventModel{DB: db},
+		db:          db,
 	}
 }
+
+// RegisterUser creates a new user account along with its personal library,
+// hands it the media records that predate accounts if it's the first, and
+// grants its permissions (media:write only for the first account). It's all one transaction, so a failure part way
+// through doesn't leave behind an account (and a taken email address) without
+// a library or permissions.
+func (m Models) RegisterUser(user *User, library *Library) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Take registrations one at a time, so that two people registering at
+	// once can't both decide they're the first and claim the old records.
+	// Reads of the users table aren't held up.
+	_, err = tx.ExecContext(ctx, `LOCK TABLE users IN SHARE ROW EXCLUSIVE MODE`)
+	if err != nil {
+		return err
+	}
+
+	var first bool
+
+	err = tx.QueryRowContext(ctx, `SELECT NOT EXISTS (SELECT 1 FROM users)`).Scan(&first)
+	if err != nil {
+		return err
+	}
+
+	err = insertUser(ctx, tx, user)
+	if err != nil {
+		return err
+	}
+
+	err = insertLibrary(ctx, tx, library, user.ID, true)
+	if err != nil {
+		return err
+	}
+
+	err = claimUnownedMedia(ctx, tx, library.ID)
+	if err != nil {
+		return err
+	}
+
+	// New accounts are read-only, apart from the first. Others are given
+	// media:write when they're made an owner or editor of a library (see
+	// AcceptInvitation()), so a guest can be shown a list without being able
+	// to change anything, even in their own library.
+	codes := []string{PermissionMediaRead}
+	if first {
+		codes = append(codes, PermissionMediaWrite)
+	}
+
+	err = addPermissionsForUser(ctx, tx, user.ID, codes...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -5,7 +5,9 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/camru/greenlight/internal/validator"
 	"github.com/lib/pq"
@@ -16,24 +18,90 @@ import (
 
 type Movie struct {
 	ID                 int64    `json:"id"`
+	LibraryID          int64    `json:"-"`
 	Title              string   `json:"title"`
-	DateWatched        string   `json:"dateWatched"`
-	DateWatchedSeasons []string `json:"dateWatchedSeasons"`
+	DateWatched        Date     `json:"dateWatched"`
+	DateWatchedSeasons []Date   `json:"dateWatchedSeasons"`
 	Tags               []string `json:"tags"`
 	Year               string   `json:"year,omitempty"`
 	MediaType          string   `json:"mediaType"`
 	Thumbnail          string   `json:"thumbnail"`
 	ImdbID             string   `json:"imdbID"`
 	Rating             float32  `json:"rating"`
-	Ratings            string   `json:"ratings"`
+	Ratings            Ratings  `json:"ratings"`
 	Watched            bool     `json:"watched"`
 	Version            int32    `json:"version"`
+	// RatingsRefreshedAt is when the external ratings were last fetched from
+	// the metadata provider, or nil if they came from the client.
+	RatingsRefreshedAt *time.Time `json:"ratingsRefreshedAt,omitempty"`
+	// PosterFile is the name of the locally cached copy of the poster at
+	// Thumbnail, or "" if there isn't one. Clients get it from
+	// /v1/movies/:id/poster.
+	PosterFile string `json:"-"`
+	// Poster is a signed /v1/movies/:id/poster URL, which can be used without
+	// an Authorization header (e.g. in an <img> tag). It's filled in by the
+	// handlers, when there's a poster to show.
+	Poster string `json:"poster,omitempty"`
+	// Progress sums up how many seasons of a series have been finished. It's
+	// only set for series which have seasons.
+	Progress *SeriesProgress `json:"progress,omitempty"`
+	// NextEpisode is the first episode of a series that hasn't been watched,
+	// and LastActivity is when one of its episodes was last marked watched.
+	// They're only filled in for lists.
+	NextEpisode  *EpisodeRef `json:"nextEpisode,omitempty"`
+	LastActivity *time.Time  `json:"lastActivity,omitempty"`
+	// AvailabilityChanged is true when the last refresh of the record's
+	// providers, in the region being listed, found something different from
+	// the refresh before it. It's only filled in for lists.
+	AvailabilityChanged bool `json:"availabilityChanged,omitempty"`
+	// LastWatched and WatchCount are worked out from the record's watch
+	// events. LastWatched is null if it's never been watched.
+	LastWatched Date `json:"lastWatched"`
+	WatchCount  int  `json:"watchCount"`
 }
 
-func ValidateMovie(v *validator.Validator, movie *Movie) {
+// ValidateMovie checks a media record before it's saved. Its tags must all be
+// in knownTags, unless knownTags is nil, in which case any tag is allowed and
+// new ones are created when the record is saved.
+func ValidateMovie(v *validator.Validator, movie *Movie, knownTags []string) {
 	v.Check(movie.Title != "", "title", "must be provided")
 	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
 
+	// Dates are compared with today's date in UTC, the same time zone the
+	// frontend's ISO timestamps are in.
+	today := Today()
+	v.Check(!movie.DateWatched.IsZero(), "dateWatched", "must be provided")
+	v.Check(!movie.DateWatched.After(today.Time), "dateWatched", "must not be in the future")
+	// A null in dateWatchedSeasons is a season that hasn't been finished.
+	for _, date := range movie.DateWatchedSeasons {
+		v.Check(!date.After(today.Time), "dateWatchedSeasons", "must not contain dates in the future")
+	}
+
+	v.Check(validator.Unique(movie.Tags), "tags", "must not contain duplicate values")
+	for _, tag := range movie.Tags {
+		v.Check(tag != "", "tags", "must not contain empty values")
+		v.Check(len(tag) <= 50, "tags", "must not contain values more than 50 bytes long")
+
+		if knownTags != nil {
+			v.Check(validator.PermittedValue(tag, knownTags...), "tags", fmt.Sprintf("%q is not a known tag", tag))
+		}
+	}
+
+	// Scores are worked out from the raw values when the record is saved, so
+	// check them the same way. Values we can't understand are kept without a
+	// score, but ones we can must fit on the 0-100 scale.
+	sources := []string{}
+	for _, rating := range movie.Ratings {
+		v.Check(rating.Source != "", "ratings", "must all have a Source")
+		v.Check(rating.Value != "", "ratings", "must all have a Value")
+
+		score := normalizeRating(rating.Value)
+		v.Check(score == nil || *score >= 0 && *score <= 100, "ratings", fmt.Sprintf("%q is not a score between 0 and 100", rating.Value))
+
+		sources = append(sources, rating.Source)
+	}
+	v.Check(validator.Unique(sources), "ratings", "must not contain more than one rating from the same Source")
+
 	// v.Check(movie.Year != 0, "year", "must be provided")
 	// v.Check(movie.Year >= 1888, "year", "must be greater than 1888")
 	// v.Check(movie.Year <= int32(time.Now().Year()), "year", "must not be in the future")
@@ -52,12 +120,12 @@ type MovieModel struct {
 	DB *sql.DB
 }
 
-// Add a placeholder method for inserting a new record in the movies table.
+// Insert a new record in the media table, along with its external ratings.
 func (m MovieModel) Insert(movie *Movie) error {
 	// Define the SQL query for inserting a new record in the movies table and
 	// returning the system-generated data.
 	query := `
-	INSERT INTO media (title, dateWatched, year, mediaType, thumbnail, imdbID, rating, ratings, watched, dateWatchedSeasons, tags)
+	INSERT INTO media (title, dateWatched, year, mediaType, thumbnail, imdbID, rating, watched, tags, library_id, ratings_refreshed_at)
 	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	RETURNING id, version, imdbID`
 
@@ -68,18 +136,60 @@ func (m MovieModel) Insert(movie *Movie) error {
 	// from the movie struct. Declaring this slice immediately next to our SQL
 	// query helps to make it nice and clear *what values are being used where*
 	// in the query.
-	args := []any{movie.Title, movie.DateWatched, movie.Year, movie.MediaType, movie.Thumbnail, movie.ImdbID, movie.Rating, movie.Ratings, movie.Watched, pq.Array(movie.DateWatchedSeasons), pq.Array((movie.Tags))}
+	args := []any{movie.Title, movie.DateWatched, movie.Year, movie.MediaType, movie.Thumbnail, movie.ImdbID, movie.Rating, movie.Watched, pq.Array((movie.Tags)), movie.LibraryID, movie.RatingsRefreshedAt}
 
-	// Use the QueryRow() method to execute the SQL query on our connection
-	// pool, passing in the args slice as a variadic parameter and scanning the
-	// system- generated id, created_at and version values into the movie
-	// struct.
-	m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.Version, &movie.ImdbID)
+	movie.Ratings = movie.Ratings.normalize()
 
-	return nil
+	// The media row and its ratings are written in one transaction, so a
+	// record never shows up without the ratings it was created with.
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Use the QueryRow() method to execute the SQL query, passing in the args
+	// slice as a variadic parameter and scanning the system- generated id,
+	// created_at and version values into the movie struct.
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.Version, &movie.ImdbID)
+	if err != nil {
+		return err
+	}
+
+	err = replaceRatings(ctx, tx, movie.ID, movie.Ratings)
+	if err != nil {
+		return err
+	}
+
+	err = insertMissingTags(ctx, tx, movie.LibraryID, movie.Tags)
+	if err != nil {
+		return err
+	}
+
+	err = replaceSeasonDates(ctx, tx, movie.ID, movie.DateWatchedSeasons)
+	if err != nil {
+		return err
+	}
+
+	// Adding something that's already been watched counts as its first
+	// viewing.
+	if movie.Watched {
+		err = insertFirstWatch(ctx, tx, movie)
+		if err != nil {
+			return err
+		}
+
+		movie.LastWatched = movie.DateWatched
+		movie.WatchCount = 1
+	}
+
+	return tx.Commit()
 }
 
-func (m MovieModel) Get(id int64) (*Movie, error) {
+// Get returns a single media record, but only if it belongs to the given
+// library. A record from another library is reported as ErrRecordNotFound so
+// that we don't leak which ids exist.
+func (m MovieModel) Get(id int64, libraryID int64) (*Movie, error) {
 	// The PostgreSQL bigserial type that we're using for the movie ID starts
 	// auto-incrementing at 1 by default, so we know that no movies will have ID
 	// values less than that. To avoid making an unnecessary database call, we
@@ -89,12 +199,16 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 	}
 
 	// Define the SQL query for retrieving the movie data.
-	query := `SELECT id, title, dateWatched, dateWatchedSeasons, tags, year, mediaType, thumbnail, imdbID, rating, ratings, watched, version
+	query := `SELECT id, library_id, title, dateWatched, ` + seasonDatesSelect + `, tags, year, mediaType, thumbnail, imdbID, rating, ` + ratingsSelect + `, watched, version, ratings_refreshed_at, poster_file, ` + lastWatchedSelect + `, ` + watchCountSelect + `,
+		season_counts.total, season_counts.finished, season_counts.latest_finished
 	FROM media 
-	WHERE id = $1`
+	` + seasonsJoin + `
+	WHERE id = $1 AND library_id = $2`
 
-	// Declare a Movie struct to hold the data returned by the query.
+	// Declare a Movie struct to hold the data returned by the query, and
+	// variables for the season counts.
 	var movie Movie
+	var seasons, finishedSeasons, latestFinishedSeason int
 
 	// Use the context.WithTimeout() function to create a context.Context which
 	// carries a 3-second timeout deadline. Note that we're using the empty
@@ -106,8 +220,9 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 	// value as a placeholder parameter, and scan the response data into the fields
 	// of the Movie struct. Importantly, notice that we need to convert the scan
 	// target for the genres column using the pq.Array() adapter function again.
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+	err := m.DB.QueryRowContext(ctx, query, id, libraryID).Scan(
 		&movie.ID,
+		&movie.LibraryID,
 		&movie.Title,
 		&movie.DateWatched,
 		pq.Array(&movie.DateWatchedSeasons),
@@ -120,6 +235,13 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 		&movie.Ratings,
 		&movie.Watched,
 		&movie.Version,
+		&movie.RatingsRefreshedAt,
+		&movie.PosterFile,
+		&movie.LastWatched,
+		&movie.WatchCount,
+		&seasons,
+		&finishedSeasons,
+		&latestFinishedSeason,
 	)
 
 	// Handle any errors. If there was no matching movie found, Scan() will
@@ -134,6 +256,10 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 		}
 	}
 
+	if movie.MediaType == MediaTypeSeries {
+		movie.Progress = newSeriesProgress(seasons, finishedSeasons, latestFinishedSeason)
+	}
+
 	return &movie, nil
 }
 
@@ -142,8 +268,8 @@ func (m MovieModel) Update(movie *Movie) error {
 	// version number.
 	query := `
 	UPDATE media
-	SET dateWatched = $1, dateWatchedSeasons = $2, tags = $3, rating = $4, version = version + 1
-	WHERE id = $5 AND version = $6
+	SET dateWatched = $1, tags = $2, rating = $3, version = version + 1
+	WHERE id = $4 AND version = $5 AND library_id = $6
 	RETURNING version`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -153,17 +279,25 @@ func (m MovieModel) Update(movie *Movie) error {
 	// parameters.
 	args := []any{
 		movie.DateWatched,
-		pq.Array(movie.DateWatchedSeasons),
 		pq.Array(movie.Tags),
 		movie.Rating,
 		movie.ID,
 		movie.Version,
+		movie.LibraryID,
 	}
 
+	// Any new tags are defined, and the season dates saved, in the same
+	// transaction as the update.
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	// Execute the SQL query. If no matching row could be found, we know the
 	// movie version has changed (or the record has been deleted) and we return
 	// our custom ErrEditConflict error.
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -174,21 +308,31 @@ func (m MovieModel) Update(movie *Movie) error {
 		}
 	}
 
-	return nil
+	err = insertMissingTags(ctx, tx, movie.LibraryID, movie.Tags)
+	if err != nil {
+		return err
+	}
+
+	err = replaceSeasonDates(ctx, tx, movie.ID, movie.DateWatchedSeasons)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (m MovieModel) Delete(id int64) error {
+func (m MovieModel) Delete(id int64, libraryID int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
 	query := `DELETE FROM media 
-	WHERE id = $1`
+	WHERE id = $1 AND library_id = $2`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	res, err := m.DB.ExecContext(ctx, query, id)
+	res, err := m.DB.ExecContext(ctx, query, id, libraryID)
 	if err != nil {
 		return err
 	}
@@ -208,17 +352,250 @@ func (m MovieModel) Delete(id int64) error {
 	return nil
 }
 
-// Create a new GetAll() method which returns a slice of movies. Although we're not
-// using them right now, we've set this up to accept the various filter parameters as
-// arguments.
-func (m MovieModel) GetAll(watched string, mediaType string, filters Filters) ([]*Movie, error) {
-	// Construct the SQL query to retrieve all movie records.
+// MovieFilters holds the query string filters for listing media. The zero
+// value of each field means that we don't filter on it.
+type MovieFilters struct {
+	Watched   string
+	MediaType string
+	// MinRT is a Rotten Tomatoes percentage, and MinIMDb and MinRating are on
+	// IMDb's 0-10 scale, the same as the user's own rating.
+	MinRT     float64
+	MinIMDb   float64
+	MinRating float64
+	// Search is free text matched against titles and years. Each word also
+	// matches longer words it's the start of.
+	Search string
+	// Tags matches records with any of the tags, and TagsAll matches records
+	// with all of them.
+	Tags    []string
+	TagsAll []string
+	// WatchedFrom and WatchedTo are dates in dateLayout, and match records
+	// watched, or with a season watched, between them (inclusive). YearFrom
+	// and YearTo match on the release year. Empty and zero values mean no
+	// limit.
+	WatchedFrom string
+	WatchedTo   string
+	YearFrom    int
+	YearTo      int
+	// InProgress matches series which have had an episode watched, and still
+	// have an episode left to watch.
+	InProgress bool
+	// Providers matches records which can be watched on any of the providers
+	// (by slug) in Region, going by the cached provider data.
+	Providers []string
+	Region    string
+	// Available matches records which can be streamed (as part of a
+	// subscription, for free or with ads) on any of the providers in Region.
+	Available []string
+}
+
+// nullIfEmpty returns nil for an empty string, so that it's passed to
+// PostgreSQL as NULL.
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// dateLayout is the format we use for dates without a time, e.g. 2023-12-24.
+const dateLayout = "2006-01-02"
+
+func ValidateMovieFilters(v *validator.Validator, mf MovieFilters) {
+	v.Check(mf.MinRT >= 0 && mf.MinRT <= 100, "min_rt", "must be between 0 and 100")
+	v.Check(mf.MinIMDb >= 0 && mf.MinIMDb <= 10, "min_imdb", "must be between 0 and 10")
+	v.Check(mf.MinRating >= 0 && mf.MinRating <= 10, "min_rating", "must be between 0 and 10")
+	v.Check(len(mf.Search) <= 200, "q", "must not be more than 200 bytes long")
+	v.Check(len(mf.Tags) <= 20, "tags", "must not contain more than 20 tags")
+	v.Check(len(mf.TagsAll) <= 20, "tags_all", "must not contain more than 20 tags")
+	v.Check(len(mf.Providers) <= 20, "provider", "must not contain more than 20 providers")
+	if len(mf.Providers) > 0 || len(mf.Available) > 0 {
+		ValidateRegion(v, mf.Region)
+	}
+
+	if mf.WatchedFrom != "" {
+		_, err := time.Parse(dateLayout, mf.WatchedFrom)
+		v.Check(err == nil, "watched_from", "must be a date in YYYY-MM-DD format")
+	}
+	if mf.WatchedTo != "" {
+		_, err := time.Parse(dateLayout, mf.WatchedTo)
+		v.Check(err == nil, "watched_to", "must be a date in YYYY-MM-DD format")
+	}
+	if mf.WatchedFrom != "" && mf.WatchedTo != "" {
+		v.Check(mf.WatchedFrom <= mf.WatchedTo, "watched_to", "must not be before watched_from")
+	}
+
+	v.Check(mf.YearFrom == 0 || mf.YearFrom >= 1888 && mf.YearFrom <= 9999, "year_from", "must be between 1888 and 9999")
+	v.Check(mf.YearTo == 0 || mf.YearTo >= 1888 && mf.YearTo <= 9999, "year_to", "must be between 1888 and 9999")
+	if mf.YearFrom != 0 && mf.YearTo != 0 {
+		v.Check(mf.YearFrom <= mf.YearTo, "year_to", "must not be before year_from")
+	}
+}
+
+// searchQuery turns free text into a tsquery string which matches records
+// containing every word, treating each word as a prefix. Anything other than
+// letters and digits is dropped, so the result is always valid tsquery syntax.
+func searchQuery(search string) string {
+	terms := []string{}
+
+	for _, word := range strings.Fields(search) {
+		word = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, word)
+
+		if word != "" {
+			terms = append(terms, word+":*")
+		}
+	}
+
+	return strings.Join(terms, " & ")
+}
+
+// scoresJoin pivots the normalized external ratings of each media row into
+// imdbRating, rtRating and metacritic columns, so they can be filtered and
+// sorted on. Titles without a rating from a source get -1, which keeps them at
+// the end when sorting from best to worst.
+const scoresJoin = `LEFT JOIN LATERAL (
+	SELECT
+		COALESCE(max(normalized_score) FILTER (WHERE source = 'Internet Movie Database'), -1) AS imdbRating,
+		COALESCE(max(normalized_score) FILTER (WHERE source = 'Rotten Tomatoes'), -1) AS rtRating,
+		COALESCE(max(normalized_score) FILTER (WHERE source = 'Metacritic'), -1) AS metacritic
+	FROM media_ratings
+	WHERE media_ratings.media_id = media.id
+) scores ON true`
+
+// searchJoin ranks each media row against the search query in $11, as a rank
+// column which can be sorted on like any other. Rows get a rank of 0 when
+// there's no search.
+const searchJoin = `CROSS JOIN LATERAL (
+	SELECT CASE WHEN $11 = '' THEN 0 ELSE ts_rank(media.search, to_tsquery('simple', $11)) END AS rank
+) search_rank`
+
+// movieSortTypes holds the SQL type of every column that media can be sorted
+// by. Cursors carry the last sort value as text, and GetAll() casts it back to
+// this type so that it compares the same way the column is ordered.
+var movieSortTypes = map[string]string{
+	"id":          "bigint",
+	"title":       "text",
+	"year":        "text",
+	"dateWatched": "date",
+	"rating":      "numeric",
+	"imdbRating":  "numeric",
+	"rtRating":    "numeric",
+	"metacritic":  "numeric",
+	"rank":        "real",
+	"activity":    "timestamptz",
+}
+
+// Create a new GetAll() method which returns a slice of movies, filtered and
+// sorted by the given filters. Only records belonging to the given library are
+// returned.
+func (m MovieModel) GetAll(libraryID int64, movieFilters MovieFilters, filters Filters) ([]*Movie, Metadata, error) {
+	sortColumn := filters.sortColumn()
+	sortType := movieSortTypes[sortColumn]
+
+	// Keyset pagination picks up after the row the cursor points at. Rows come
+	// after it if their sort value is further along in the sort direction, or
+	// if it's the same value and the id (which is always sorted ascending) is
+	// greater.
+	comparison := ">"
+	if filters.sortDirection() == "DESC" {
+		comparison = "<"
+	}
+
+	// Construct the SQL query to retrieve all movie records. The count(*) OVER()
+	// window function adds the total number of records matching the filters
+	// (ignoring LIMIT and OFFSET) to every row, which we need for the pagination
+	// metadata. It's worked out in a subquery, before the keyset condition, so
+	// that the total stays the same from one page to the next. The sort value
+	// of each row is selected as text too, so that we can build the next cursor
+	// from the last row.
 	query := fmt.Sprintf(`
-	SELECT id, title, dateWatched, year, mediaType, thumbnail, imdbID, rating, ratings, watched, version, dateWatchedSeasons, tags
+	SELECT * FROM (
+	SELECT count(*) OVER() AS total_records, id, library_id, title, dateWatched, year, mediaType, thumbnail, imdbID, rating, `+ratingsSelect+`, watched, version, `+seasonDatesSelect+`, tags, ratings_refreshed_at, `+lastWatchedSelect+`, `+watchCountSelect+`,
+		season_counts.total, season_counts.finished, season_counts.latest_finished,
+		next_episode.season_number, next_episode.episode_number, episode_activity.last_activity,
+		coalesce(provider_refresh.availability_changed, false), (%[1]s) AS sort_value, (%[1]s)::text AS sort_text
 	FROM media
-	WHERE (watched = true AND $1 = 'true' OR watched = false AND $1 = 'false' OR $1 = '')
-	AND (mediaType = $2 OR $2 = '')
-	ORDER BY %s %s, id ASC`, filters.sortColumn(), filters.sortDirection())
+	`+scoresJoin+`
+	`+searchJoin+`
+	`+seasonsJoin+`
+	`+episodesJoin+`
+	LEFT JOIN media_provider_refreshes provider_refresh ON provider_refresh.media_id = media.id AND provider_refresh.region = $20
+	WHERE library_id = $1
+	AND (watched = true AND $2 = 'true' OR watched = false AND $2 = 'false' OR $2 = '')
+	AND (mediaType = $3 OR $3 = '')
+	AND ($4::numeric = 0 OR scores.rtRating >= $4)
+	AND ($5::numeric = 0 OR scores.imdbRating >= $5 * 10)
+	AND ($6::numeric = 0 OR rating >= $6)
+	AND ($11 = '' OR media.search @@ to_tsquery('simple', $11))
+	AND (coalesce(cardinality($12::text[]), 0) = 0 OR tags && $12)
+	AND (coalesce(cardinality($13::text[]), 0) = 0 OR tags @> $13)
+	AND ($14::date IS NULL AND $15::date IS NULL OR EXISTS (
+		SELECT 1
+		FROM (
+			SELECT dateWatched WHERE watched
+			UNION ALL SELECT watched_on FROM seasons WHERE seasons.media_id = media.id
+			UNION ALL SELECT watched_on FROM watch_events WHERE watch_events.media_id = media.id
+		) AS dates(d)
+		WHERE ($14 IS NULL OR d >= $14) AND ($15 IS NULL OR d <= $15)
+	))
+	AND ($16::integer = 0 OR substring(year from '\d{4}')::integer >= $16)
+	AND ($17::integer = 0 OR substring(year from '\d{4}')::integer <= $17)
+	AND (NOT $18::boolean OR episode_activity.last_activity IS NOT NULL AND next_episode.season_number IS NOT NULL)
+	AND (coalesce(cardinality($19::text[]), 0) = 0 OR EXISTS (
+		SELECT 1 FROM media_providers
+		WHERE media_providers.media_id = media.id AND media_providers.region = $20 AND media_providers.slug = ANY($19)
+	))
+	AND (coalesce(cardinality($21::text[]), 0) = 0 OR EXISTS (
+		SELECT 1 FROM media_providers
+		WHERE media_providers.media_id = media.id AND media_providers.region = $20 AND media_providers.slug = ANY($21)
+		AND media_providers.type IN ('flatrate', 'free', 'ads')
+	))
+	) AS filtered
+	WHERE ($9::%[3]s IS NULL OR sort_value %[4]s $9 OR (sort_value = $9 AND id > $10))
+	ORDER BY sort_value %[2]s, id ASC
+	LIMIT $7 OFFSET $8`, sortColumn, filters.sortDirection(), sortType, comparison)
+
+	// Without a cursor the keyset arguments are NULL, which switches that
+	// condition off.
+	var cursorValue, cursorID any
+	if filters.Cursor != "" {
+		c, err := decodeCursor(filters.Cursor)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		cursorValue, cursorID = c.Value, c.ID
+	}
+
+	// Ask for one more row than fits on the page, so we can tell whether there
+	// is a next page without a second query.
+	args := []any{
+		libraryID,
+		movieFilters.Watched,
+		movieFilters.MediaType,
+		movieFilters.MinRT,
+		movieFilters.MinIMDb,
+		movieFilters.MinRating,
+		filters.limit() + 1,
+		filters.offset(),
+		cursorValue,
+		cursorID,
+		searchQuery(movieFilters.Search),
+		pq.Array(movieFilters.Tags),
+		pq.Array(movieFilters.TagsAll),
+		nullIfEmpty(movieFilters.WatchedFrom),
+		nullIfEmpty(movieFilters.WatchedTo),
+		movieFilters.YearFrom,
+		movieFilters.YearTo,
+		movieFilters.InProgress,
+		pq.Array(movieFilters.Providers),
+		movieFilters.Region,
+		pq.Array(movieFilters.Available),
+	}
 
 	// Create a context with a 3-second timeout.
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -226,27 +603,38 @@ func (m MovieModel) GetAll(watched string, mediaType string, filters Filters) ([
 
 	// Use QueryContext() to execute the query. This returns a sql.Rows resultset
 	// containing the result.
-	rows, err := m.DB.QueryContext(ctx, query, watched, mediaType)
+	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, Metadata{}, err
 	}
 
 	// Importantly, defer a call to rows.Close() to ensure that the resultset is closed
 	// before GetAll() returns.
 	defer rows.Close()
 
-	// Initialize an empty slice to hold the movie data.
+	// Declare a totalRecords variable, and initialize an empty slice to hold
+	// the movie data, along with the sort value of each movie.
+	totalRecords := 0
 	movies := []*Movie{}
+	sortValues := []string{}
 
 	// Use rows.Next to iterate through the rows in the resultset.
 	for rows.Next() {
 		// Initialize an empty Movie struct to hold the data for an individual movie.
 		var movie Movie
+		var seasons, finishedSeasons, latestFinishedSeason int
+		var nextSeason, nextEpisode *int
+		var sortValue string
+		// sortKey is the typed sort value the query pages by. We only need
+		// sortValue, its text form.
+		var sortKey any
 
 		// Scan the values from the row into the Movie struct. Again, note that we're
 		// using the pq.Array() adapter on the genres field here.
 		err := rows.Scan(
+			&totalRecords,
 			&movie.ID,
+			&movie.LibraryID,
 			&movie.Title,
 			&movie.DateWatched,
 			&movie.Year,
@@ -259,21 +647,300 @@ func (m MovieModel) GetAll(watched string, mediaType string, filters Filters) ([
 			&movie.Version,
 			pq.Array(&movie.DateWatchedSeasons),
 			pq.Array(&movie.Tags),
+			&movie.RatingsRefreshedAt,
+			&movie.LastWatched,
+			&movie.WatchCount,
+			&seasons,
+			&finishedSeasons,
+			&latestFinishedSeason,
+			&nextSeason,
+			&nextEpisode,
+			&movie.LastActivity,
+			&movie.AvailabilityChanged,
+			&sortKey,
+			&sortValue,
 		)
 		if err != nil {
-			return nil, err
+			return nil, Metadata{}, err
+		}
+
+		if nextSeason != nil && nextEpisode != nil {
+			movie.NextEpisode = &EpisodeRef{Season: *nextSeason, Episode: *nextEpisode}
+		}
+
+		if movie.MediaType == MediaTypeSeries {
+			movie.Progress = newSeriesProgress(seasons, finishedSeasons, latestFinishedSeason)
 		}
 
 		// Add the Movie struct to the slice.
 		movies = append(movies, &movie)
+		sortValues = append(sortValues, sortValue)
 	}
 
 	// When the rows.Next() loop has finished, call rows.Err() to retrieve any error
 	// that was encountered during the iteration.
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	// Generate a Metadata struct, passing in the total record count and
+	// pagination parameters from the client. Page numbers mean nothing when
+	// paging by cursor, and the total is the number of records matching the
+	// filters, not the number left to fetch.
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	if filters.Cursor != "" {
+		metadata = Metadata{PageSize: filters.PageSize, TotalRecords: totalRecords}
+	}
+
+	// If we got the extra row then there's another page. Drop the extra row
+	// and point the next cursor at the last row on this page.
+	if len(movies) > filters.limit() {
+		movies = movies[:filters.limit()]
+
+		last := len(movies) - 1
+		metadata.NextCursor = encodeCursor(cursor{
+			Sort:  filters.Sort,
+			Value: sortValues[last],
+			ID:    movies[last].ID,
+		})
+	}
+
+	// If everything went OK, then return the slice of movies and the metadata.
+	return movies, metadata, nil
+}
+
+// claimUnownedMedia moves every media record that predates user accounts into
+// the given library, inside the caller's transaction. It only does anything
+// while no library owns any media yet, so the first person to register (or
+// the next one, if that registration failed) inherits the existing records.
+// Any tags on the records that the library doesn't have yet are added to it.
+func claimUnownedMedia(ctx context.Context, tx *sql.Tx, libraryID int64) error {
+	query := `
+	WITH claimed AS (
+		UPDATE media
+		SET library_id = $1
+		WHERE library_id IS NULL
+		AND NOT EXISTS (SELECT 1 FROM media WHERE library_id IS NOT NULL)
+		RETURNING tags
+	)
+	INSERT INTO tags (library_id, name, display_name)
+	SELECT DISTINCT $1::bigint, tag, tag
+	FROM claimed, unnest(claimed.tags) AS tag
+	ON CONFLICT (library_id, name) DO NOTHING`
+
+	_, err := tx.ExecContext(ctx, query, libraryID)
+	return err
+}
+
+// GetStaleRatings returns up to limit to-watch records, across every library,
+// whose external ratings were last refreshed before the cutoff (or never).
+// Only the fields needed to refresh the ratings are filled in.
+func (m MovieModel) GetStaleRatings(cutoff time.Time, limit int) ([]*Movie, error) {
+	query := `
+	SELECT id, library_id, title, imdbID, version
+	FROM media
+	WHERE watched = false
+	AND imdbID <> ''
+	AND (ratings_refreshed_at IS NULL OR ratings_refreshed_at < $1)
+	ORDER BY ratings_refreshed_at ASC NULLS FIRST, id ASC
+	LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, cutoff, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(
+			&movie.ID,
+			&movie.LibraryID,
+			&movie.Title,
+			&movie.ImdbID,
+			&movie.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+// GetStaleProviders returns up to limit to-watch records, across every
+// library, whose watch providers in region were last fetched before the
+// cutoff (or never). Only the fields needed to refetch them are filled in.
+func (m MovieModel) GetStaleProviders(region string, cutoff time.Time, limit int) ([]*Movie, error) {
+	query := `
+	SELECT media.id, media.library_id, media.title, media.imdbID
+	FROM media
+	LEFT JOIN media_provider_refreshes ON media_provider_refreshes.media_id = media.id AND media_provider_refreshes.region = $1
+	WHERE media.watched = false
+	AND media.imdbID <> ''
+	AND (media_provider_refreshes.refreshed_at IS NULL OR media_provider_refreshes.refreshed_at < $2)
+	ORDER BY media_provider_refreshes.refreshed_at ASC NULLS FIRST, media.id ASC
+	LIMIT $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, region, cutoff, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(
+			&movie.ID,
+			&movie.LibraryID,
+			&movie.Title,
+			&movie.ImdbID,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	// If everything went OK, then return the slice of movies.
 	return movies, nil
 }
+
+// UpdateRatings stores freshly fetched external ratings and records when they
+// were fetched. Like Update(), it returns ErrEditConflict if the record has
+// changed since it was read.
+func (m MovieModel) UpdateRatings(movie *Movie) error {
+	query := `
+	UPDATE media
+	SET ratings_refreshed_at = NOW(), version = version + 1
+	WHERE id = $1 AND version = $2
+	RETURNING version, ratings_refreshed_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	movie.Ratings = movie.Ratings.normalize()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, movie.ID, movie.Version).Scan(&movie.Version, &movie.RatingsRefreshedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = replaceRatings(ctx, tx, movie.ID, movie.Ratings)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetMissingPosters returns up to limit records, across every library, which
+// have a remote poster but no cached copy of it. Records are returned in id
+// order starting after afterID, so that callers can work through them in
+// batches without seeing the ones that failed again. Only the fields needed
+// to cache the poster are filled in.
+func (m MovieModel) GetMissingPosters(afterID int64, limit int) ([]*Movie, error) {
+	query := `
+	SELECT id, library_id, title, thumbnail
+	FROM media
+	WHERE poster_file = ''
+	AND (thumbnail LIKE 'http://%' OR thumbnail LIKE 'https://%')
+	AND id > $1
+	ORDER BY id ASC
+	LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(
+			&movie.ID,
+			&movie.LibraryID,
+			&movie.Title,
+			&movie.Thumbnail,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+// SetPosterFile records the name of the cached copy of a record's poster. The
+// cached copy is only ever derived from Thumbnail, so this doesn't bump the
+// version and can't cause an edit conflict for clients.
+func (m MovieModel) SetPosterFile(id int64, posterFile string) error {
+	query := `
+	UPDATE media
+	SET poster_file = $1
+	WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, posterFile, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
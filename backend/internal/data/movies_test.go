@@ -0,0 +1,30 @@
+package data
+
+import "testing"
+
+func TestSearchQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		search string
+		want   string
+	}{
+		{"single word", "elf", "elf:*"},
+		{"several words", "home alone", "home:* & alone:*"},
+		{"lowercased", "Home ALONE", "home:* & alone:*"},
+		{"punctuation dropped", "Home Alone 2: Lost", "home:* & alone:* & 2:* & lost:*"},
+		{"tsquery syntax dropped", "a & !b | (c:*)", "a:* & b:* & c:*"},
+		{"words of only punctuation dropped", "dash & lily", "dash:* & lily:*"},
+		{"extra whitespace", "  elf \t ", "elf:*"},
+		{"unicode letters kept", "Amélie", "amélie:*"},
+		{"empty", "", ""},
+		{"nothing searchable", "!!! ???", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchQuery(tt.search); got != tt.want {
+				t.Errorf("searchQuery(%q) = %q; want %q", tt.search, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/camru/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+// A Provider is a service a title can be watched on in some region, and how:
+// as part of a subscription ("flatrate"), for free, with ads, or to rent or
+// buy. Slug is a short lowercase form of the name, e.g. "netflix", which is
+// what the provider filter matches on.
+type Provider struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	LogoPath string `json:"logo_path"`
+	Type     string `json:"type"`
+}
+
+// ValidateRegion checks a region is an upper case ISO 3166-1 country code,
+// which is what TMDB keys its providers by.
+func ValidateRegion(v *validator.Validator, region string) {
+	v.Check(validator.Matches(region, validator.RegionRX), "region", "must be a two-letter country code, e.g. US")
+}
+
+// ValidateSubscriptions checks a list of subscribed provider slugs.
+func ValidateSubscriptions(v *validator.Validator, slugs []string) {
+	v.Check(slugs != nil, "providers", "must be provided")
+	v.Check(len(slugs) <= 50, "providers", "must not contain more than 50 providers")
+	v.Check(validator.Unique(slugs), "providers", "must not contain duplicate values")
+
+	for _, slug := range slugs {
+		v.Check(validator.Matches(slug, validator.SlugRX), "providers", "must be provider slugs, e.g. netflix")
+	}
+}
+
+// ProviderModel caches where each title can be watched, per region.
+type ProviderModel struct {
+	DB *sql.DB
+}
+
+// GetForMedia returns the cached providers for a media record in a region,
+// along with when they were fetched. If they've never been fetched the time
+// is nil.
+func (m ProviderModel) GetForMedia(mediaID int64, region string) ([]Provider, *time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var refreshedAt time.Time
+
+	query := `
+	SELECT refreshed_at
+	FROM media_provider_refreshes
+	WHERE media_id = $1 AND region = $2`
+
+	err := m.DB.QueryRowContext(ctx, query, mediaID, region).Scan(&refreshedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return []Provider{}, nil, nil
+		default:
+			return nil, nil, err
+		}
+	}
+
+	query = `
+	SELECT provider_id, name, slug, logo_path, type
+	FROM media_providers
+	WHERE media_id = $1 AND region = $2
+	ORDER BY array_position(ARRAY['flatrate', 'free', 'ads', 'rent', 'buy'], type), name`
+
+	rows, err := m.DB.QueryContext(ctx, query, mediaID, region)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	providers := []Provider{}
+
+	for rows.Next() {
+		var provider Provider
+
+		err := rows.Scan(&provider.ID, &provider.Name, &provider.Slug, &provider.LogoPath, &provider.Type)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		providers = append(providers, provider)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return providers, &refreshedAt, nil
+}
+
+// Replace swaps the cached providers for a media record in a region with the
+// given ones, and records that they were just fetched. If the title had been
+// fetched before and the providers aren't the same as last time, the refresh
+// is flagged as an availability change.
+func (m ProviderModel) Replace(mediaID int64, region string, providers []Provider) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var refreshed bool
+
+	query := `
+	SELECT EXISTS(SELECT 1 FROM media_provider_refreshes WHERE media_id = $1 AND region = $2)`
+
+	err = tx.QueryRowContext(ctx, query, mediaID, region).Scan(&refreshed)
+	if err != nil {
+		return err
+	}
+
+	// Collect what we had before, keyed the same way as the primary key.
+	query = `
+	DELETE FROM media_providers
+	WHERE media_id = $1 AND region = $2
+	RETURNING provider_id, type`
+
+	rows, err := tx.QueryContext(ctx, query, mediaID, region)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	previous := map[string]bool{}
+
+	for rows.Next() {
+		var p Provider
+
+		err := rows.Scan(&p.ID, &p.Type)
+		if err != nil {
+			return err
+		}
+
+		previous[providerKey(p)] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	query = `
+	INSERT INTO media_providers (media_id, region, provider_id, name, slug, logo_path, type)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT DO NOTHING`
+
+	current := map[string]bool{}
+
+	for _, p := range providers {
+		_, err = tx.ExecContext(ctx, query, mediaID, region, p.ID, p.Name, p.Slug, p.LogoPath, p.Type)
+		if err != nil {
+			return err
+		}
+
+		current[providerKey(p)] = true
+	}
+
+	// The first fetch for a title isn't a change, as there was nothing to
+	// compare it with.
+	changed := false
+	if refreshed {
+		changed = len(previous) != len(current)
+		for key := range current {
+			if !previous[key] {
+				changed = true
+			}
+		}
+	}
+
+	query = `
+	INSERT INTO media_provider_refreshes (media_id, region, refreshed_at, availability_changed)
+	VALUES ($1, $2, NOW(), $3)
+	ON CONFLICT (media_id, region) DO UPDATE SET refreshed_at = NOW(), availability_changed = $3`
+
+	_, err = tx.ExecContext(ctx, query, mediaID, region, changed)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetSubscriptions returns the slugs of the providers a user subscribes to.
+func (m ProviderModel) GetSubscriptions(userID int64) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+	SELECT slug
+	FROM user_providers
+	WHERE user_id = $1
+	ORDER BY slug`
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	slugs := []string{}
+
+	for rows.Next() {
+		var slug string
+
+		err := rows.Scan(&slug)
+		if err != nil {
+			return nil, err
+		}
+
+		slugs = append(slugs, slug)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return slugs, nil
+}
+
+// SetSubscriptions replaces the providers a user subscribes to.
+func (m ProviderModel) SetSubscriptions(userID int64, slugs []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM user_providers WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO user_providers (user_id, slug)
+	SELECT $1, unnest($2::text[])`
+
+	_, err = tx.ExecContext(ctx, query, userID, pq.Array(slugs))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// providerKey identifies a provider within a title's providers for a region.
+func providerKey(p Provider) string {
+	return fmt.Sprintf("%d:%s", p.ID, p.Type)
+}
@@ -0,0 +1,136 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Define the rating sources we know how to sort and filter by. These are the
+// names OMDb uses.
+const (
+	RatingSourceIMDb           = "Internet Movie Database"
+	RatingSourceRottenTomatoes = "Rotten Tomatoes"
+	RatingSourceMetacritic     = "Metacritic"
+)
+
+// A Rating is a score from a single external source. Value is the score exactly
+// as the source reports it (e.g. "7.1/10" or "85%") and Score is the same score
+// on a 0-100 scale, or nil if Value couldn't be understood.
+type Rating struct {
+	Source string   `json:"Source"`
+	Value  string   `json:"Value"`
+	Score  *float64 `json:"Score"`
+}
+
+// NewRating returns a Rating with its Score worked out from the raw value.
+func NewRating(source, value string) Rating {
+	return Rating{
+		Source: source,
+		Value:  value,
+		Score:  normalizeRating(value),
+	}
+}
+
+// normalizeRating converts a percentage ("85%") or a ratio ("7.1/10",
+// "64/100") into a score out of 100.
+func normalizeRating(value string) *float64 {
+	value = strings.TrimSpace(value)
+
+	if strings.HasSuffix(value, "%") {
+		score, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil {
+			return nil
+		}
+		return &score
+	}
+
+	numerator, denominator, found := strings.Cut(value, "/")
+	if !found {
+		return nil
+	}
+
+	n, err := strconv.ParseFloat(numerator, 64)
+	if err != nil {
+		return nil
+	}
+
+	d, err := strconv.ParseFloat(denominator, 64)
+	if err != nil || d == 0 {
+		return nil
+	}
+
+	score := math.Round(n/d*100*100) / 100
+	return &score
+}
+
+// Ratings is the list of external ratings for a media record, in the order the
+// source reported them.
+type Ratings []Rating
+
+// Scan implements the sql.Scanner interface, so that the JSON array built by
+// ratingsSelect can be read straight into a Movie.
+func (r *Ratings) Scan(src any) error {
+	var b []byte
+
+	switch src := src.(type) {
+	case []byte:
+		b = src
+	case string:
+		b = []byte(src)
+	case nil:
+		*r = Ratings{}
+		return nil
+	default:
+		return errors.New("unsupported type for ratings")
+	}
+
+	return json.Unmarshal(b, r)
+}
+
+// ratingsSelect is a correlated subquery which gathers the ratings of the
+// surrounding media row into a JSON array, ready to be scanned into Ratings.
+const ratingsSelect = `COALESCE((
+	SELECT json_agg(json_build_object('Source', source, 'Value', raw_value, 'Score', normalized_score) ORDER BY position)
+	FROM media_ratings
+	WHERE media_ratings.media_id = media.id
+), '[]')`
+
+// normalize returns a copy of the ratings with every Score recalculated from
+// its raw Value, so that clients can't send us scores of their own.
+func (r Ratings) normalize() Ratings {
+	normalized := make(Ratings, len(r))
+
+	for i, rating := range r {
+		normalized[i] = NewRating(rating.Source, rating.Value)
+	}
+
+	return normalized
+}
+
+// replaceRatings swaps the stored ratings for a media record with the given
+// ones. It must be run inside the caller's transaction.
+func replaceRatings(ctx context.Context, tx *sql.Tx, mediaID int64, ratings Ratings) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM media_ratings WHERE media_id = $1`, mediaID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO media_ratings (media_id, position, source, raw_value, normalized_score)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (media_id, source) DO NOTHING`
+
+	for i, rating := range ratings {
+		_, err = tx.ExecContext(ctx, query, mediaID, i+1, rating.Source, rating.Value, rating.Score)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
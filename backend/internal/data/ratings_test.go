@@ -0,0 +1,42 @@
+package data
+
+import "testing"
+
+func TestNormalizeRating(t *testing.T) {
+	tests := []struct {
+		value string
+		want  *float64
+	}{
+		{"85%", float(85)},
+		{"7.1/10", float(71)},
+		{"64/100", float(64)},
+		{"8.25/10", float(82.5)},
+		{"2/3", float(66.67)},
+		{" 94% ", float(94)},
+		{"0/10", float(0)},
+		{"N/A", nil},
+		{"", nil},
+		{"85", nil},
+		{"abc%", nil},
+		{"7/0", nil},
+		{"7/ten", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got := normalizeRating(tt.value)
+
+			switch {
+			case got == nil && tt.want == nil:
+			case got == nil || tt.want == nil:
+				t.Errorf("normalizeRating(%q) = %v; want %v", tt.value, got, tt.want)
+			case *got != *tt.want:
+				t.Errorf("normalizeRating(%q) = %v; want %v", tt.value, *got, *tt.want)
+			}
+		})
+	}
+}
+
+func float(f float64) *float64 {
+	return &f
+}
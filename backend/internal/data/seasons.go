@@ -0,0 +1,311 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/camru/greenlight/internal/validator"
+)
+
+// MediaTypeSeries is the mediaType of TV series, which are the only records
+// with seasons.
+const MediaTypeSeries = "series"
+
+// A Season is one season of a series. WatchedOn is when it was finished, and
+// is zero (null in JSON) until then.
+type Season struct {
+	ID           int64     `json:"-"`
+	MediaID      int64     `json:"media_id"`
+	Number       int       `json:"number"`
+	EpisodeCount int       `json:"episode_count"`
+	WatchedOn    Date      `json:"watched_on"`
+	Rating       *float32  `json:"rating"`
+	Episodes     []Episode `json:"episodes"`
+}
+
+// An Episode is one episode of a season. WatchedOn is zero until it's been
+// watched.
+type Episode struct {
+	Number    int    `json:"number"`
+	Title     string `json:"title"`
+	WatchedOn Date   `json:"watched_on"`
+}
+
+func ValidateSeason(v *validator.Validator, season *Season) {
+	v.Check(season.Number >= 1 && season.Number <= 100, "number", "must be between 1 and 100")
+	v.Check(season.EpisodeCount >= 0 && season.EpisodeCount <= 500, "episode_count", "must be between 0 and 500")
+	v.Check(!season.WatchedOn.After(Today().Time), "watched_on", "must not be in the future")
+
+	if season.Rating != nil {
+		v.Check(*season.Rating >= 0 && *season.Rating <= 10, "rating", "must be between 0 and 10")
+	}
+}
+
+// SeriesProgress sums up how far through a series we are, e.g. "S3 of 5
+// finished". Finished is the number of seasons with a watched date, and
+// LatestFinished is the highest numbered of them.
+type SeriesProgress struct {
+	Finished       int    `json:"finished"`
+	Total          int    `json:"total"`
+	LatestFinished int    `json:"latest_finished"`
+	Summary        string `json:"summary"`
+}
+
+// newSeriesProgress returns the progress for a series with the given season
+// counts, or nil if it has no seasons yet.
+func newSeriesProgress(total, finished, latestFinished int) *SeriesProgress {
+	if total == 0 {
+		return nil
+	}
+
+	progress := &SeriesProgress{
+		Finished:       finished,
+		Total:          total,
+		LatestFinished: latestFinished,
+	}
+
+	switch {
+	case finished == 0:
+		progress.Summary = fmt.Sprintf("not started, %d seasons", total)
+	case finished == total:
+		progress.Summary = fmt.Sprintf("all %d seasons finished", total)
+	default:
+		progress.Summary = fmt.Sprintf("S%d of %d finished", latestFinished, total)
+	}
+
+	return progress
+}
+
+// An EpisodeRef points at an episode of a series, such as the next one to
+// watch.
+type EpisodeRef struct {
+	Season  int `json:"season"`
+	Episode int `json:"episode"`
+}
+
+// seasonDatesSelect is a correlated subquery which rebuilds the old
+// dateWatchedSeasons array from the seasons table. Element i is the date
+// season i+1 was finished, or NULL if it hasn't been, and the array stops at
+// the last finished season.
+const seasonDatesSelect = `(
+	SELECT coalesce(array_agg(seasons.watched_on ORDER BY n), '{}')
+	FROM generate_series(1, (
+		SELECT coalesce(max(number), 0) FROM seasons WHERE seasons.media_id = media.id AND watched_on IS NOT NULL
+	)) AS n
+	LEFT JOIN seasons ON seasons.media_id = media.id AND seasons.number = n
+)`
+
+// seasonsJoin counts the seasons of each media row, for its SeriesProgress.
+const seasonsJoin = `CROSS JOIN LATERAL (
+	SELECT
+		count(*) AS total,
+		count(watched_on) AS finished,
+		coalesce(max(number) FILTER (WHERE watched_on IS NOT NULL), 0) AS latest_finished
+	FROM seasons
+	WHERE seasons.media_id = media.id
+) season_counts`
+
+// episodesJoin finds the next unwatched episode of each media row (the first
+// one, in season and episode order) and when its episodes were last marked
+// as watched. activity is the same as last_activity, but never NULL, so it
+// can be sorted on and used in cursors.
+const episodesJoin = `LEFT JOIN LATERAL (
+	SELECT seasons.number AS season_number, episodes.number AS episode_number
+	FROM seasons
+	INNER JOIN episodes ON episodes.season_id = seasons.id
+	WHERE seasons.media_id = media.id AND episodes.watched_on IS NULL
+	ORDER BY seasons.number, episodes.number
+	LIMIT 1
+) next_episode ON true
+CROSS JOIN LATERAL (
+	SELECT max(episodes.marked_at) AS last_activity, coalesce(max(episodes.marked_at), 'epoch') AS activity
+	FROM seasons
+	INNER JOIN episodes ON episodes.season_id = seasons.id
+	WHERE seasons.media_id = media.id
+) episode_activity`
+
+// replaceSeasonDates saves a dateWatchedSeasons array into the seasons table,
+// creating seasons as needed. Seasons after the end of the array are marked
+// as not finished. It must be run inside the caller's transaction.
+func replaceSeasonDates(ctx context.Context, tx *sql.Tx, mediaID int64, dates []Date) error {
+	_, err := tx.ExecContext(ctx, `UPDATE seasons SET watched_on = NULL WHERE media_id = $1 AND number > $2`, mediaID, len(dates))
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO seasons (media_id, number, watched_on)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (media_id, number) DO UPDATE SET watched_on = EXCLUDED.watched_on`
+
+	for i, date := range dates {
+		_, err = tx.ExecContext(ctx, query, mediaID, i+1, date)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Define the SeasonModel type.
+type SeasonModel struct {
+	DB *sql.DB
+}
+
+// Get returns a season of a series, along with its episodes. The caller is
+// responsible for checking that the series belongs to the user's library.
+func (m SeasonModel) Get(mediaID int64, number int) (*Season, error) {
+	query := `
+	SELECT id, media_id, number, episode_count, watched_on, rating
+	FROM seasons
+	WHERE media_id = $1 AND number = $2`
+
+	var season Season
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, mediaID, number).Scan(
+		&season.ID,
+		&season.MediaID,
+		&season.Number,
+		&season.EpisodeCount,
+		&season.WatchedOn,
+		&season.Rating,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	query = `
+	SELECT number, title, watched_on
+	FROM episodes
+	WHERE season_id = $1
+	ORDER BY number`
+
+	rows, err := m.DB.QueryContext(ctx, query, season.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	season.Episodes = []Episode{}
+
+	for rows.Next() {
+		var episode Episode
+
+		err := rows.Scan(&episode.Number, &episode.Title, &episode.WatchedOn)
+		if err != nil {
+			return nil, err
+		}
+
+		season.Episodes = append(season.Episodes, episode)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &season, nil
+}
+
+// Put creates or replaces a season of a series. The season gets an episode
+// for each of its EpisodeCount, and loses any episodes beyond that.
+func (m SeasonModel) Put(season *Season) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO seasons (media_id, number, episode_count, watched_on, rating)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (media_id, number) DO UPDATE
+	SET episode_count = EXCLUDED.episode_count, watched_on = EXCLUDED.watched_on, rating = EXCLUDED.rating
+	RETURNING id`
+
+	args := []any{season.MediaID, season.Number, season.EpisodeCount, season.WatchedOn, season.Rating}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&season.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM episodes WHERE season_id = $1 AND number > $2`, season.ID, season.EpisodeCount)
+	if err != nil {
+		return err
+	}
+
+	query = `
+	INSERT INTO episodes (season_id, number)
+	SELECT $1, generate_series(1, $2::integer)
+	ON CONFLICT (season_id, number) DO NOTHING`
+
+	_, err = tx.ExecContext(ctx, query, season.ID, season.EpisodeCount)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// WatchEpisode marks an episode of a series as watched on the given date. If
+// that was the last unwatched episode of the season, the season is marked as
+// finished on the same date. ErrRecordNotFound is returned if the season or
+// episode doesn't exist.
+func (m SeasonModel) WatchEpisode(mediaID int64, seasonNumber, episodeNumber int, watchedOn Date) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE episodes
+	SET watched_on = $4, marked_at = NOW()
+	FROM seasons
+	WHERE episodes.season_id = seasons.id
+	AND seasons.media_id = $1 AND seasons.number = $2 AND episodes.number = $3
+	RETURNING seasons.id`
+
+	var seasonID int64
+
+	err = tx.QueryRowContext(ctx, query, mediaID, seasonNumber, episodeNumber, watchedOn).Scan(&seasonID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	query = `
+	UPDATE seasons
+	SET watched_on = $2
+	WHERE id = $1 AND watched_on IS NULL
+	AND NOT EXISTS (SELECT 1 FROM episodes WHERE season_id = $1 AND watched_on IS NULL)`
+
+	_, err = tx.ExecContext(ctx, query, seasonID, watchedOn)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
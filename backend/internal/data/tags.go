@@ -0,0 +1,352 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/camru/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+// Define a custom ErrDuplicateTag error.
+var (
+	ErrDuplicateTag = errors.New("duplicate tag")
+)
+
+// seasonLayout is the format of a tag's season dates, e.g. "11-15" for the
+// 15th of November. They're stored the same way, so they sort like dates.
+const seasonLayout = "01-02"
+
+// A Tag is one of the labels that can be put on media records in a library.
+// Name is what's stored on the records, and the rest is for showing it. Tags
+// with a season (e.g. christmas, from 11-15 to 01-06) are only relevant part
+// of the year. A season whose end comes before its start wraps around the
+// new year. Count is the number of records with the tag.
+type Tag struct {
+	ID          int64   `json:"id"`
+	LibraryID   int64   `json:"-"`
+	Name        string  `json:"name"`
+	DisplayName string  `json:"display_name"`
+	Color       string  `json:"color"`
+	Icon        string  `json:"icon"`
+	SeasonStart *string `json:"season_start"`
+	SeasonEnd   *string `json:"season_end"`
+	Count       int     `json:"count"`
+	Version     int32   `json:"version"`
+}
+
+// defaultTags are the tags every new library starts with.
+var defaultTags = []Tag{
+	{Name: "christmas", DisplayName: "Christmas", Color: "#c0392b", Icon: "🎄", SeasonStart: stringPtr("11-15"), SeasonEnd: stringPtr("01-06")},
+	{Name: "halloween", DisplayName: "Halloween", Color: "#e67e22", Icon: "🎃", SeasonStart: stringPtr("10-01"), SeasonEnd: stringPtr("10-31")},
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func ValidateTagName(v *validator.Validator, name string) {
+	v.Check(name != "", "name", "must be provided")
+	v.Check(len(name) <= 50, "name", "must not be more than 50 bytes long")
+}
+
+func ValidateTag(v *validator.Validator, tag *Tag) {
+	ValidateTagName(v, tag.Name)
+
+	v.Check(tag.DisplayName != "", "display_name", "must be provided")
+	v.Check(len(tag.DisplayName) <= 50, "display_name", "must not be more than 50 bytes long")
+	v.Check(tag.Color == "" || validator.Matches(tag.Color, validator.ColorRX), "color", "must be a hex color like #c0392b")
+	v.Check(len(tag.Icon) <= 32, "icon", "must not be more than 32 bytes long")
+
+	v.Check((tag.SeasonStart == nil) == (tag.SeasonEnd == nil), "season", "must have both a start and an end, or neither")
+	if tag.SeasonStart != nil {
+		_, err := time.Parse(seasonLayout, *tag.SeasonStart)
+		v.Check(err == nil, "season_start", "must be a month and day like 11-15")
+	}
+	if tag.SeasonEnd != nil {
+		_, err := time.Parse(seasonLayout, *tag.SeasonEnd)
+		v.Check(err == nil, "season_end", "must be a month and day like 01-06")
+	}
+}
+
+// Define the TagModel type.
+type TagModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a new tag to a library. ErrDuplicateTag is returned if the
+// library already has a tag with the same name.
+func (m TagModel) Insert(tag *Tag) error {
+	query := `
+	INSERT INTO tags (library_id, name, display_name, color, icon, season_start, season_end)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING id, version`
+
+	args := []any{tag.LibraryID, tag.Name, tag.DisplayName, tag.Color, tag.Icon, tag.SeasonStart, tag.SeasonEnd}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&tag.ID, &tag.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "tags_library_id_name_key"`:
+			return ErrDuplicateTag
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tagsSelect picks out the columns of a Tag, along with the number of media
+// records in the library that have it.
+const tagsSelect = `
+	SELECT id, library_id, name, display_name, color, icon, season_start, season_end, version,
+		(SELECT count(*) FROM media WHERE media.library_id = tags.library_id AND tags.name = ANY(media.tags)) AS usage_count
+	FROM tags`
+
+func scanTag(row interface{ Scan(...any) error }, tag *Tag) error {
+	return row.Scan(
+		&tag.ID,
+		&tag.LibraryID,
+		&tag.Name,
+		&tag.DisplayName,
+		&tag.Color,
+		&tag.Icon,
+		&tag.SeasonStart,
+		&tag.SeasonEnd,
+		&tag.Version,
+		&tag.Count,
+	)
+}
+
+// Get returns a single tag from a library, by name.
+func (m TagModel) Get(libraryID int64, name string) (*Tag, error) {
+	query := tagsSelect + `
+	WHERE library_id = $1 AND name = $2`
+
+	var tag Tag
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := scanTag(m.DB.QueryRowContext(ctx, query, libraryID, name), &tag)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &tag, nil
+}
+
+// GetAllForLibrary returns every tag in the library, most used first. If
+// currentSeason is true, only tags whose season includes today (in UTC) are
+// returned.
+func (m TagModel) GetAllForLibrary(libraryID int64, currentSeason bool) ([]*Tag, error) {
+	query := tagsSelect + `
+	WHERE library_id = $1
+	AND ($2 = ''
+		OR (season_start <= season_end AND $2 BETWEEN season_start AND season_end)
+		OR (season_start > season_end AND ($2 >= season_start OR $2 <= season_end)))
+	ORDER BY usage_count DESC, name ASC`
+
+	// Today is in UTC, like the dates that media records are checked against.
+	today := ""
+	if currentSeason {
+		today = Today().Format(seasonLayout)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, libraryID, today)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*Tag{}
+
+	for rows.Next() {
+		var tag Tag
+
+		err := scanTag(rows, &tag)
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, &tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// GetNamesForLibrary returns the names of every tag in the library.
+func (m TagModel) GetNamesForLibrary(libraryID int64) ([]string, error) {
+	query := `
+	SELECT name
+	FROM tags
+	WHERE library_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, libraryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+
+	for rows.Next() {
+		var name string
+
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+
+		names = append(names, name)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// Update saves changes to a tag. If the tag was renamed from oldName, every
+// media record in the library with the tag is renamed too, in the same
+// transaction.
+func (m TagModel) Update(tag *Tag, oldName string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE tags
+	SET name = $1, display_name = $2, color = $3, icon = $4, season_start = $5, season_end = $6, version = version + 1
+	WHERE id = $7 AND library_id = $8 AND version = $9
+	RETURNING version`
+
+	args := []any{tag.Name, tag.DisplayName, tag.Color, tag.Icon, tag.SeasonStart, tag.SeasonEnd, tag.ID, tag.LibraryID, tag.Version}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&tag.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "tags_library_id_name_key"`:
+			return ErrDuplicateTag
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	// Only touch the media if the name actually changed. Renaming a tag to its
+	// own name would otherwise find every record already "has the new name"
+	// below, and strip the tag from all of them. Records which do have both
+	// names just lose the old one, so they don't end up with it twice.
+	if tag.Name != oldName {
+		query = `
+		UPDATE media
+		SET tags = CASE WHEN $3 = ANY(tags) THEN array_remove(tags, $2) ELSE array_replace(tags, $2, $3) END,
+			version = version + 1
+		WHERE library_id = $1 AND $2 = ANY(tags)`
+
+		_, err = tx.ExecContext(ctx, query, tag.LibraryID, oldName, tag.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a tag from the library, and from every media record in it,
+// in one transaction.
+func (m TagModel) Delete(libraryID int64, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE library_id = $1 AND name = $2`, libraryID, name)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+	UPDATE media
+	SET tags = array_remove(tags, $2), version = version + 1
+	WHERE library_id = $1 AND $2 = ANY(tags)`
+
+	_, err = tx.ExecContext(ctx, query, libraryID, name)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertDefaultTags gives a new library the default tags. It must be run
+// inside the caller's transaction.
+func insertDefaultTags(ctx context.Context, tx *sql.Tx, libraryID int64) error {
+	query := `
+	INSERT INTO tags (library_id, name, display_name, color, icon, season_start, season_end)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	for _, tag := range defaultTags {
+		_, err := tx.ExecContext(ctx, query, libraryID, tag.Name, tag.DisplayName, tag.Color, tag.Icon, tag.SeasonStart, tag.SeasonEnd)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertMissingTags adds a plain tag to the library for each of the names it
+// doesn't already have, so that every tag on a media record is defined. It
+// must be run inside the caller's transaction.
+func insertMissingTags(ctx context.Context, tx *sql.Tx, libraryID int64, names []string) error {
+	query := `
+	INSERT INTO tags (library_id, name, display_name)
+	SELECT $1, name, name
+	FROM unnest($2::text[]) AS name
+	ON CONFLICT (library_id, name) DO NOTHING`
+
+	_, err := tx.ExecContext(ctx, query, libraryID, pq.Array(names))
+	return err
+}
@@ -0,0 +1,162 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/camru/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+// A WatchEvent records one viewing of a media record, so rewatches each get
+// their own date, rating and note. WatchedWith lists who else was watching.
+type WatchEvent struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	MediaID     int64     `json:"media_id"`
+	WatchedOn   Date      `json:"watched_on"`
+	Rating      *float32  `json:"rating"`
+	Note        string    `json:"note"`
+	WatchedWith []string  `json:"watched_with"`
+}
+
+func ValidateWatchEvent(v *validator.Validator, event *WatchEvent) {
+	v.Check(!event.WatchedOn.IsZero(), "watched_on", "must be provided")
+	v.Check(!event.WatchedOn.After(Today().Time), "watched_on", "must not be in the future")
+
+	if event.Rating != nil {
+		v.Check(*event.Rating >= 0 && *event.Rating <= 10, "rating", "must be between 0 and 10")
+	}
+
+	v.Check(len(event.Note) <= 1000, "note", "must not be more than 1000 bytes long")
+
+	v.Check(len(event.WatchedWith) <= 20, "watched_with", "must not contain more than 20 people")
+	for _, person := range event.WatchedWith {
+		v.Check(person != "", "watched_with", "must not contain empty values")
+		v.Check(len(person) <= 100, "watched_with", "must not contain values more than 100 bytes long")
+	}
+}
+
+// lastWatchedSelect and watchCountSelect are correlated subqueries which
+// derive a media record's lastWatched and watchCount from its watch events.
+const (
+	lastWatchedSelect = `(SELECT max(watched_on) FROM watch_events WHERE watch_events.media_id = media.id)`
+	watchCountSelect  = `(SELECT count(*) FROM watch_events WHERE watch_events.media_id = media.id)`
+)
+
+// Define the WatchEventModel type.
+type WatchEventModel struct {
+	DB *sql.DB
+}
+
+// Insert records a new watch event. The caller is responsible for checking
+// that the media record belongs to the user's library.
+func (m WatchEventModel) Insert(event *WatchEvent) error {
+	if event.WatchedWith == nil {
+		event.WatchedWith = []string{}
+	}
+
+	args := []any{event.MediaID, event.WatchedOn, event.Rating, event.Note, pq.Array(event.WatchedWith)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, insertWatchEventQuery, args...).Scan(&event.ID, &event.CreatedAt)
+}
+
+const insertWatchEventQuery = `
+	INSERT INTO watch_events (media_id, watched_on, rating, note, watched_with)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at`
+
+// insertFirstWatch records the viewing a watched media record was created
+// with. It must be run inside the caller's transaction.
+func insertFirstWatch(ctx context.Context, tx *sql.Tx, movie *Movie) error {
+	// A rating of 0 means the record hasn't been rated.
+	var rating *float32
+	if movie.Rating != 0 {
+		rating = &movie.Rating
+	}
+
+	var id int64
+	var createdAt time.Time
+
+	return tx.QueryRowContext(ctx, insertWatchEventQuery, movie.ID, movie.DateWatched, rating, "", pq.Array([]string{})).Scan(&id, &createdAt)
+}
+
+// GetAllForMedia returns every watch event for a media record, most recent
+// first.
+func (m WatchEventModel) GetAllForMedia(mediaID int64) ([]*WatchEvent, error) {
+	query := `
+	SELECT id, created_at, media_id, watched_on, rating, note, watched_with
+	FROM watch_events
+	WHERE media_id = $1
+	ORDER BY watched_on DESC, id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*WatchEvent{}
+
+	for rows.Next() {
+		var event WatchEvent
+
+		err := rows.Scan(
+			&event.ID,
+			&event.CreatedAt,
+			&event.MediaID,
+			&event.WatchedOn,
+			&event.Rating,
+			&event.Note,
+			pq.Array(&event.WatchedWith),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Delete removes one of a media record's watch events.
+func (m WatchEventModel) Delete(id int64, mediaID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+	DELETE FROM watch_events
+	WHERE id = $1 AND media_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, query, id, mediaID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// fakeTitles is the catalogue served by the fake OMDb server.
+var fakeTitles = []Title{
+	{
+		Title:      "Elf",
+		Year:       "2003",
+		Runtime:    "97 min",
+		Genre:      "Adventure, Comedy, Family",
+		Director:   "Jon Favreau",
+		Plot:       "Raised as an oversized elf, Buddy travels from the North Pole to New York City to meet his biological father.",
+		Poster:     "https://m.media-amazon.com/images/M/elf.jpg",
+		Ratings:    []Rating{{"Internet Movie Database", "7.1/10"}, {"Rotten Tomatoes", "85%"}, {"Metacritic", "64/100"}},
+		Metascore:  "64",
+		ImdbRating: "7.1",
+		ImdbID:     "tt0319343",
+		Type:       "movie",
+	},
+	{
+		Title:      "Home Alone",
+		Year:       "1990",
+		Runtime:    "103 min",
+		Genre:      "Comedy, Family",
+		Director:   "Chris Columbus",
+		Plot:       "An eight-year-old troublemaker must protect his house from a pair of burglars when he is accidentally left home alone by his family during Christmas vacation.",
+		Poster:     "https://m.media-amazon.com/images/M/home-alone.jpg",
+		Ratings:    []Rating{{"Internet Movie Database", "7.7/10"}, {"Rotten Tomatoes", "66%"}, {"Metacritic", "63/100"}},
+		Metascore:  "63",
+		ImdbRating: "7.7",
+		ImdbID:     "tt0099785",
+		Type:       "movie",
+	},
+	{
+		Title:      "Home Alone 2: Lost in New York",
+		Year:       "1992",
+		Runtime:    "120 min",
+		Genre:      "Adventure, Comedy, Crime",
+		Director:   "Chris Columbus",
+		Plot:       "One year after Kevin was left home alone and had to defeat a pair of bumbling burglars, he accidentally finds himself stranded in New York City.",
+		Poster:     "https://m.media-amazon.com/images/M/home-alone-2.jpg",
+		Ratings:    []Rating{{"Internet Movie Database", "6.9/10"}, {"Rotten Tomatoes", "35%"}, {"Metacritic", "46/100"}},
+		Metascore:  "46",
+		ImdbRating: "6.9",
+		ImdbID:     "tt0104431",
+		Type:       "movie",
+	},
+	{
+		Title:        "Dash & Lily",
+		Year:         "2020",
+		Runtime:      "26 min",
+		Genre:        "Comedy, Romance",
+		Director:     "N/A",
+		Plot:         "Dash and Lily trade dares and challenges in a notebook they pass back and forth at locations all across New York City during Christmas.",
+		Poster:       "https://m.media-amazon.com/images/M/dash-and-lily.jpg",
+		Ratings:      []Rating{{"Internet Movie Database", "7.3/10"}},
+		Metascore:    "N/A",
+		ImdbRating:   "7.3",
+		ImdbID:       "tt9741310",
+		Type:         "series",
+		TotalSeasons: "1",
+	},
+}
+
+// NewFakeOMDbServer starts an in-process HTTP server that answers search (s=)
+// and lookup (i=) requests the way OMDb does, from a small fixed catalogue. It
+// lets the OMDb client run without network access or an API key. Callers
+// should Close() the server when they're done with it.
+func NewFakeOMDbServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		qs := r.URL.Query()
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if qs.Get("apikey") == "" {
+			writeFakeJSON(w, map[string]string{"Response": "False", "Error": "No API key provided."})
+			return
+		}
+
+		switch {
+		case qs.Get("i") != "":
+			for _, title := range fakeTitles {
+				if title.ImdbID == qs.Get("i") {
+					writeFakeJSON(w, struct {
+						Title
+						Response string
+					}{title, "True"})
+					return
+				}
+			}
+			writeFakeJSON(w, map[string]string{"Response": "False", "Error": "Incorrect IMDb ID."})
+
+		case qs.Get("s") != "":
+			results := []SearchResult{}
+			for _, title := range fakeTitles {
+				if strings.Contains(strings.ToLower(title.Title), strings.ToLower(qs.Get("s"))) {
+					results = append(results, SearchResult{title.Title, title.Year, title.ImdbID, title.Type, title.Poster})
+				}
+			}
+			if len(results) == 0 {
+				writeFakeJSON(w, map[string]string{"Response": "False", "Error": "Movie not found!"})
+				return
+			}
+			writeFakeJSON(w, map[string]any{"Search": results, "totalResults": len(results), "Response": "True"})
+
+		default:
+			writeFakeJSON(w, map[string]string{"Response": "False", "Error": "Incorrect IMDb ID."})
+		}
+	}))
+}
+
+func writeFakeJSON(w http.ResponseWriter, v any) {
+	json.NewEncoder(w).Encode(v)
+}
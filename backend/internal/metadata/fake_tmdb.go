@@ -0,0 +1,85 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+)
+
+// fakeTMDbTitle is an entry in the fake TMDB server's catalogue: a title's
+// TMDB id and type, and its providers by region and type.
+type fakeTMDbTitle struct {
+	imdbID    string
+	tmdbID    int
+	mediaType string
+	providers map[string]map[string][]tmdbProvider
+}
+
+var (
+	fakeNetflix   = tmdbProvider{8, "Netflix", "/pbpMk2JmcoNnQwx5JGpXngfoWtp.jpg"}
+	fakeDisney    = tmdbProvider{337, "Disney Plus", "/97yvRBw1GzX7fXprcF80er19ot.jpg"}
+	fakeMax       = tmdbProvider{1899, "Max", "/6Q3ZYUNA9Hsgj6iWnVsw2gR5V6z.jpg"}
+	fakeAppleTV   = tmdbProvider{2, "Apple TV", "/9ghgSC0MA082EL6HLCW3GalykFD.jpg"}
+	fakePrimeRent = tmdbProvider{10, "Amazon Video", "/5NyLm42TmCqCMOZFvH4fcoSNKEW.jpg"}
+)
+
+// fakeTMDbTitles covers the same titles as the fake OMDb server.
+var fakeTMDbTitles = []fakeTMDbTitle{
+	{"tt0319343", 10719, "movie", map[string]map[string][]tmdbProvider{
+		"US": {"flatrate": {fakeMax}, "rent": {fakeAppleTV, fakePrimeRent}, "buy": {fakeAppleTV}},
+		"GB": {"rent": {fakeAppleTV}},
+	}},
+	{"tt0099785", 771, "movie", map[string]map[string][]tmdbProvider{
+		"US": {"flatrate": {fakeDisney}, "buy": {fakeAppleTV}},
+		"GB": {"flatrate": {fakeDisney}},
+	}},
+	{"tt0104431", 772, "movie", map[string]map[string][]tmdbProvider{
+		"US": {"flatrate": {fakeDisney}, "rent": {fakePrimeRent}},
+	}},
+	{"tt9741310", 110356, "tv", map[string]map[string][]tmdbProvider{
+		"US": {"flatrate": {fakeNetflix}},
+		"GB": {"flatrate": {fakeNetflix}},
+	}},
+}
+
+// NewFakeTMDbServer starts an in-process HTTP server that answers /find and
+// watch/providers requests the way TMDB does, from a small fixed catalogue.
+// Callers should Close() the server when they're done with it.
+func NewFakeTMDbServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.URL.Query().Get("api_key") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			writeFakeJSON(w, map[string]any{"status_code": 7, "status_message": "Invalid API key: You must be granted a valid key."})
+			return
+		}
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+		switch {
+		case len(parts) == 2 && parts[0] == "find":
+			results := map[string][]map[string]int{"movie_results": {}, "tv_results": {}}
+			for _, title := range fakeTMDbTitles {
+				if title.imdbID == parts[1] {
+					results[title.mediaType+"_results"] = []map[string]int{{"id": title.tmdbID}}
+				}
+			}
+			writeFakeJSON(w, results)
+
+		case len(parts) == 4 && parts[2] == "watch" && parts[3] == "providers":
+			for _, title := range fakeTMDbTitles {
+				if title.mediaType == parts[0] && strconv.Itoa(title.tmdbID) == parts[1] {
+					writeFakeJSON(w, map[string]any{"id": title.tmdbID, "results": title.providers})
+					return
+				}
+			}
+			fallthrough
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			writeFakeJSON(w, map[string]any{"status_code": 34, "status_message": "The resource you requested could not be found."})
+		}
+	}))
+}
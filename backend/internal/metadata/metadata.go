@@ -0,0 +1,52 @@
+// Package metadata looks up information about movies and series from external
+// providers, so that API keys stay on the server instead of in the frontend
+// bundle.
+package metadata
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned when the provider has no title matching the request.
+var ErrNotFound = errors.New("title not found")
+
+// A SearchResult is a single hit from a title search. The JSON keys match the
+// ones OMDb uses, which is what the frontend already understands.
+type SearchResult struct {
+	Title  string `json:"Title"`
+	Year   string `json:"Year"`
+	ImdbID string `json:"imdbID"`
+	Type   string `json:"Type"`
+	Poster string `json:"Poster"`
+}
+
+// A Rating is a score from a single review source, e.g. "Rotten Tomatoes" and
+// "94%".
+type Rating struct {
+	Source string `json:"Source"`
+	Value  string `json:"Value"`
+}
+
+// Title holds the full details of a movie or series.
+type Title struct {
+	Title        string   `json:"Title"`
+	Year         string   `json:"Year"`
+	Runtime      string   `json:"Runtime"`
+	Genre        string   `json:"Genre"`
+	Director     string   `json:"Director"`
+	Plot         string   `json:"Plot"`
+	Poster       string   `json:"Poster"`
+	Ratings      []Rating `json:"Ratings"`
+	Metascore    string   `json:"Metascore"`
+	ImdbRating   string   `json:"imdbRating"`
+	ImdbID       string   `json:"imdbID"`
+	Type         string   `json:"Type"`
+	TotalSeasons string   `json:"totalSeasons,omitempty"`
+}
+
+// Provider is implemented by anything that can search for and look up titles.
+type Provider interface {
+	Search(ctx context.Context, title string) ([]SearchResult, error)
+	Lookup(ctx context.Context, imdbID string) (*Title, error)
+}
@@ -0,0 +1,112 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OMDbClient talks to the OMDb API (https://www.omdbapi.com).
+type OMDbClient struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewOMDbClient returns a client for the OMDb API at baseURL, authenticating
+// with apiKey.
+func NewOMDbClient(baseURL, apiKey string) *OMDbClient {
+	return &OMDbClient{
+		baseURL: baseURL,
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// OMDb always responds with a 200 OK, and reports failures with a Response
+// field of "False" and an Error message instead.
+type omdbEnvelope struct {
+	Response string `json:"Response"`
+	Error    string `json:"Error"`
+}
+
+func (e omdbEnvelope) err() error {
+	if e.Response != "False" {
+		return nil
+	}
+
+	if strings.HasSuffix(e.Error, "not found!") || e.Error == "Incorrect IMDb ID." {
+		return ErrNotFound
+	}
+
+	return fmt.Errorf("omdb: %s", e.Error)
+}
+
+// Search returns the titles matching a (partial) title.
+func (c *OMDbClient) Search(ctx context.Context, title string) ([]SearchResult, error) {
+	var body struct {
+		omdbEnvelope
+		Search []SearchResult `json:"Search"`
+	}
+
+	err := c.get(ctx, url.Values{"s": {title}}, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	// A search with no results is not an error as far as we're concerned.
+	err = body.err()
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return []SearchResult{}, nil
+		}
+		return nil, err
+	}
+
+	return body.Search, nil
+}
+
+// Lookup returns the full details for a single title.
+func (c *OMDbClient) Lookup(ctx context.Context, imdbID string) (*Title, error) {
+	var body struct {
+		omdbEnvelope
+		Title
+	}
+
+	err := c.get(ctx, url.Values{"i": {imdbID}}, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = body.err(); err != nil {
+		return nil, err
+	}
+
+	return &body.Title, nil
+}
+
+func (c *OMDbClient) get(ctx context.Context, params url.Values, dst any) error {
+	params.Set("apikey", c.apiKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("omdb: unexpected status %s", res.Status)
+	}
+
+	return json.NewDecoder(res.Body).Decode(dst)
+}
@@ -0,0 +1,87 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestOMDbClientSearch(t *testing.T) {
+	server := NewFakeOMDbServer()
+	defer server.Close()
+
+	client := NewOMDbClient(server.URL, "fake")
+
+	tests := []struct {
+		name    string
+		title   string
+		wantIDs []string
+	}{
+		{"single match", "elf", []string{"tt0319343"}},
+		{"several matches", "Home Alone", []string{"tt0099785", "tt0104431"}},
+		{"case insensitive", "DASH", []string{"tt9741310"}},
+		{"no matches", "Die Hard", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := client.Search(context.Background(), tt.title)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(results) != len(tt.wantIDs) {
+				t.Fatalf("got %d results; want %d", len(results), len(tt.wantIDs))
+			}
+
+			for i, result := range results {
+				if result.ImdbID != tt.wantIDs[i] {
+					t.Errorf("result %d: got %q; want %q", i, result.ImdbID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestOMDbClientLookup(t *testing.T) {
+	server := NewFakeOMDbServer()
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		imdbID    string
+		wantTitle string
+		wantErr   error
+	}{
+		{"movie", "tt0319343", "Elf", nil},
+		{"series", "tt9741310", "Dash & Lily", nil},
+		{"unknown id", "tt0000000", "", ErrNotFound},
+	}
+
+	client := NewOMDbClient(server.URL, "fake")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title, err := client.Lookup(context.Background(), tt.imdbID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v; want %v", err, tt.wantErr)
+			}
+
+			if err == nil && title.Title != tt.wantTitle {
+				t.Errorf("got title %q; want %q", title.Title, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestOMDbClientMissingAPIKey(t *testing.T) {
+	server := NewFakeOMDbServer()
+	defer server.Close()
+
+	client := NewOMDbClient(server.URL, "")
+
+	_, err := client.Lookup(context.Background(), "tt0319343")
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("got error %v; want an API key error", err)
+	}
+}
@@ -0,0 +1,180 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The kinds of availability TMDB reports for a provider.
+const (
+	ProviderTypeFlatrate = "flatrate"
+	ProviderTypeFree     = "free"
+	ProviderTypeAds      = "ads"
+	ProviderTypeRent     = "rent"
+	ProviderTypeBuy      = "buy"
+)
+
+// A WatchProvider is a service a title can be watched on in some region, and
+// how: as part of a subscription ("flatrate"), for free, with ads, or to rent
+// or buy. Slug is a short lowercase form of the name, e.g. "netflix".
+type WatchProvider struct {
+	ID       int
+	Name     string
+	Slug     string
+	LogoPath string
+	Type     string
+}
+
+// WatchProviderSource is implemented by anything that can tell us where a
+// title can be watched.
+type WatchProviderSource interface {
+	WatchProviders(ctx context.Context, imdbID, region string) ([]WatchProvider, error)
+}
+
+// ProviderSlug turns a provider name into its slug, e.g. "Amazon Prime Video"
+// into "amazon-prime-video".
+func ProviderSlug(name string) string {
+	var b strings.Builder
+
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+
+	return b.String()
+}
+
+// TMDbClient talks to The Movie Database API (https://developer.themoviedb.org).
+type TMDbClient struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewTMDbClient returns a client for the TMDB API at baseURL, authenticating
+// with apiKey.
+func NewTMDbClient(baseURL, apiKey string) *TMDbClient {
+	return &TMDbClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type tmdbProvider struct {
+	ProviderID   int    `json:"provider_id"`
+	ProviderName string `json:"provider_name"`
+	LogoPath     string `json:"logo_path"`
+}
+
+// WatchProviders returns the providers for a title in a region (an ISO 3166-1
+// country code such as "US"). TMDB identifies titles by its own ids, so the
+// IMDb id is looked up with /find first, the same way the frontend does.
+func (c *TMDbClient) WatchProviders(ctx context.Context, imdbID, region string) ([]WatchProvider, error) {
+	var found struct {
+		MovieResults []struct {
+			ID int `json:"id"`
+		} `json:"movie_results"`
+		TVResults []struct {
+			ID int `json:"id"`
+		} `json:"tv_results"`
+	}
+
+	err := c.get(ctx, "/find/"+url.PathEscape(imdbID), url.Values{"external_source": {"imdb_id"}}, &found)
+	if err != nil {
+		return nil, err
+	}
+
+	var path string
+	switch {
+	case len(found.MovieResults) > 0:
+		path = fmt.Sprintf("/movie/%d/watch/providers", found.MovieResults[0].ID)
+	case len(found.TVResults) > 0:
+		path = fmt.Sprintf("/tv/%d/watch/providers", found.TVResults[0].ID)
+	default:
+		return nil, ErrNotFound
+	}
+
+	var body struct {
+		Results map[string]map[string]json.RawMessage `json:"results"`
+	}
+
+	err = c.get(ctx, path, url.Values{}, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each region has a list of providers for each type, plus a "link" to
+	// TMDB's page for the title, which we don't need.
+	providers := []WatchProvider{}
+
+	types := []string{ProviderTypeFlatrate, ProviderTypeFree, ProviderTypeAds, ProviderTypeRent, ProviderTypeBuy}
+
+	for _, providerType := range types {
+		raw, ok := body.Results[strings.ToUpper(region)][providerType]
+		if !ok {
+			continue
+		}
+
+		var list []tmdbProvider
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+
+		for _, p := range list {
+			providers = append(providers, WatchProvider{
+				ID:       p.ProviderID,
+				Name:     p.ProviderName,
+				Slug:     ProviderSlug(p.ProviderName),
+				LogoPath: p.LogoPath,
+				Type:     providerType,
+			})
+		}
+	}
+
+	return providers, nil
+}
+
+func (c *TMDbClient) get(ctx context.Context, path string, params url.Values, dst any) error {
+	params.Set("api_key", c.apiKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// Unlike OMDb, TMDB uses status codes, with a status_message in the body.
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	if res.StatusCode != http.StatusOK {
+		var body struct {
+			StatusMessage string `json:"status_message"`
+		}
+		json.NewDecoder(res.Body).Decode(&body)
+		return fmt.Errorf("tmdb: unexpected status %s: %s", res.Status, body.StatusMessage)
+	}
+
+	return json.NewDecoder(res.Body).Decode(dst)
+}
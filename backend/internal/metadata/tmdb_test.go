@@ -0,0 +1,115 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestProviderSlug(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Netflix", "netflix"},
+		{"Amazon Prime Video", "amazon-prime-video"},
+		{"Disney Plus", "disney-plus"},
+		{"Paramount+ Amazon Channel", "paramount-amazon-channel"},
+		{"  HBO   Max ", "hbo-max"},
+		{"Sky Go!", "sky-go"},
+		{"MUBI", "mubi"},
+		{"BBC iPlayer 2", "bbc-iplayer-2"},
+		{"Crème de la Crème", "crème-de-la-crème"},
+		{"", ""},
+		{"+++", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProviderSlug(tt.name); got != tt.want {
+				t.Errorf("ProviderSlug(%q) = %q; want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTMDbClientWatchProviders(t *testing.T) {
+	server := NewFakeTMDbServer()
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		imdbID  string
+		region  string
+		want    []string // "slug/type", in order
+		wantErr error
+	}{
+		{
+			name:   "movie with every type",
+			imdbID: "tt0319343",
+			region: "US",
+			want:   []string{"max/flatrate", "apple-tv/rent", "amazon-video/rent", "apple-tv/buy"},
+		},
+		{
+			name:   "region is case insensitive",
+			imdbID: "tt0319343",
+			region: "gb",
+			want:   []string{"apple-tv/rent"},
+		},
+		{
+			name:   "series",
+			imdbID: "tt9741310",
+			region: "US",
+			want:   []string{"netflix/flatrate"},
+		},
+		{
+			name:   "region without providers",
+			imdbID: "tt0104431",
+			region: "GB",
+			want:   []string{},
+		},
+		{
+			name:    "unknown title",
+			imdbID:  "tt0000000",
+			region:  "US",
+			wantErr: ErrNotFound,
+		},
+	}
+
+	// A trailing slash on the base URL is trimmed off.
+	client := NewTMDbClient(server.URL+"/", "fake")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providers, err := client.WatchProviders(context.Background(), tt.imdbID, tt.region)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v; want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if len(providers) != len(tt.want) {
+				t.Fatalf("got %d providers; want %d", len(providers), len(tt.want))
+			}
+
+			for i, p := range providers {
+				if got := p.Slug + "/" + p.Type; got != tt.want[i] {
+					t.Errorf("provider %d: got %q; want %q", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTMDbClientMissingAPIKey(t *testing.T) {
+	server := NewFakeTMDbServer()
+	defer server.Close()
+
+	client := NewTMDbClient(server.URL, "")
+
+	_, err := client.WatchProviders(context.Background(), "tt0319343", "US")
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("got error %v; want an API key error", err)
+	}
+}
@@ -0,0 +1,244 @@
+// Package posters keeps local copies of poster images, so that we don't
+// depend on the remote URLs (which tend to stop working) to show them.
+package posters
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxPosterSize caps how much we'll download for a single poster.
+const maxPosterSize = 10 << 20
+
+var (
+	// ErrNotImage is returned when a poster URL doesn't point at an image we
+	// know how to serve.
+	ErrNotImage = errors.New("posters: not a supported image")
+	// ErrInvalidName is returned when asked to open something which isn't
+	// the name of a cached poster.
+	ErrInvalidName = errors.New("posters: invalid file name")
+	// ErrForbiddenAddress is returned when a poster URL points at (or
+	// redirects to) anything other than a public internet address.
+	ErrForbiddenAddress = errors.New("posters: refusing to fetch from a non-public address")
+)
+
+// forbiddenNetworks are the address ranges, on top of the loopback, private
+// and link-local ones the net package knows about, that posters are never
+// fetched from.
+var forbiddenNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("192.0.0.0/24"),
+	mustParseCIDR("198.18.0.0/15"),
+	mustParseCIDR("240.0.0.0/4"),
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// isPublic reports whether ip is an ordinary internet address.
+func isPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+
+	for _, network := range forbiddenNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newClient returns the HTTP client posters are fetched with. Poster URLs come
+// from clients, so unless allowPrivate is set (which only tests do) it refuses
+// to connect to anything but public addresses. The check happens as each
+// connection is made, after DNS resolution, so it also covers redirects and
+// host names that resolve to internal addresses.
+func newClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || !allowPrivate && !isPublic(ip) {
+				return ErrForbiddenAddress
+			}
+
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		// No Proxy, as a proxy would make the connections on our behalf and
+		// bypass the address check.
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("posters: too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrForbiddenAddress
+			}
+			return nil
+		},
+	}
+}
+
+// extensions maps the image types we cache to the extension they're saved
+// with.
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// nameRX matches the names Fetch() gives cached posters: the hex SHA-256 of
+// the image, and its extension. Resized variants have their width in the name
+// too, e.g. "<hash>-w200.jpg".
+var nameRX = regexp.MustCompile(`^[0-9a-f]{64}(-w[0-9]+)?\.(jpg|png|gif|webp)$`)
+
+// Cache is a directory of poster images. Each image is named after a hash of
+// its content, so a name always refers to the same image.
+type Cache struct {
+	dir    string
+	client *http.Client
+}
+
+// New returns a cache which keeps its images in dir. The directory is created
+// when the first image is saved.
+func New(dir string) *Cache {
+	return &Cache{
+		dir:    dir,
+		client: newClient(false),
+	}
+}
+
+// IsRemote reports whether a thumbnail is a URL we can download. OMDb uses
+// "N/A" for titles without a poster.
+func IsRemote(thumbnail string) bool {
+	return strings.HasPrefix(thumbnail, "https://") || strings.HasPrefix(thumbnail, "http://")
+}
+
+// Fetch downloads the image at url into the cache, and returns its name.
+func (c *Cache) Fetch(ctx context.Context, url string) (string, error) {
+	if !IsRemote(url) {
+		return "", ErrForbiddenAddress
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("posters: unexpected status %s", res.Status)
+	}
+
+	// Read one byte more than we allow, so we can tell if it was too big.
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxPosterSize+1))
+	if err != nil {
+		return "", err
+	}
+
+	if len(body) > maxPosterSize {
+		return "", fmt.Errorf("posters: image is larger than %d bytes", maxPosterSize)
+	}
+
+	// Go by the content rather than the Content-Type header, which servers
+	// don't always get right.
+	ext, ok := extensions[http.DetectContentType(body)]
+	if !ok {
+		return "", ErrNotImage
+	}
+
+	sum := sha256.Sum256(body)
+	name := hex.EncodeToString(sum[:]) + ext
+
+	err = c.write(name, body)
+	if err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// write saves an image in the cache. It's written to a temporary file first
+// and renamed into place, so that a reader never sees half an image.
+func (c *Cache) write(name string, body []byte) error {
+	err := os.MkdirAll(c.dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(body)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(c.dir, name))
+}
+
+// Open opens a cached image by name. If it isn't in the cache the error
+// satisfies errors.Is(err, fs.ErrNotExist).
+func (c *Cache) Open(name string) (*os.File, error) {
+	if !nameRX.MatchString(name) {
+		return nil, ErrInvalidName
+	}
+
+	return os.Open(filepath.Join(c.dir, name))
+}
+
+// ETag returns the entity tag for a cached image. As images are named after
+// their content, the name (without its extension) is all we need.
+func ETag(name string) string {
+	return `"` + strings.TrimSuffix(name, filepath.Ext(name)) + `"`
+}
@@ -0,0 +1,170 @@
+package posters
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"100.64.0.1", false},
+		{"198.18.0.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::ffff:127.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := isPublic(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("isPublic(%s) = %t; want %t", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetch(t *testing.T) {
+	poster := encodePNG(t, image.NewGray(image.Rect(0, 0, 10, 15)))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/poster.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(poster)
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>Not a poster</body></html>"))
+	})
+	mux.HandleFunc("/huge.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(append(poster, make([]byte, maxPosterSize)...))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		url      string
+		wantExt  string
+		wantErr  error
+		anyError bool
+	}{
+		{name: "image", url: server.URL + "/poster.png", wantExt: ".png"},
+		{name: "not an image", url: server.URL + "/page.html", wantErr: ErrNotImage},
+		{name: "too big", url: server.URL + "/huge.png", anyError: true},
+		{name: "missing", url: server.URL + "/missing.png", anyError: true},
+		{name: "not http", url: "file:///etc/passwd", wantErr: ErrForbiddenAddress},
+	}
+
+	cache := &Cache{dir: t.TempDir(), client: newClient(true)}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, err := cache.Fetch(context.Background(), tt.url)
+
+			switch {
+			case tt.anyError:
+				if err == nil {
+					t.Fatal("got no error; want one")
+				}
+				return
+			case !errors.Is(err, tt.wantErr):
+				t.Fatalf("got error %v; want %v", err, tt.wantErr)
+			case err != nil:
+				return
+			}
+
+			if !nameRX.MatchString(name) || !strings.HasSuffix(name, tt.wantExt) {
+				t.Errorf("got name %q; want a hash ending in %s", name, tt.wantExt)
+			}
+
+			file, err := cache.Open(name)
+			if err != nil {
+				t.Fatalf("opening cached poster: %v", err)
+			}
+			file.Close()
+		})
+	}
+}
+
+func TestFetchRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"loopback", server.URL},
+		{"link-local", "http://169.254.169.254/latest/meta-data/"},
+		{"localhost by name", strings.Replace(server.URL, "127.0.0.1", "localhost", 1)},
+	}
+
+	cache := New(t.TempDir())
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cache.Fetch(context.Background(), tt.url)
+			if !errors.Is(err, ErrForbiddenAddress) {
+				t.Errorf("got error %v; want %v", err, ErrForbiddenAddress)
+			}
+		})
+	}
+}
+
+func TestOpenInvalidName(t *testing.T) {
+	tests := []string{
+		"",
+		"../../etc/passwd",
+		"poster.jpg",
+		strings.Repeat("a", 64) + ".exe",
+		strings.Repeat("a", 64) + "-w200.orig",
+		strings.Repeat("A", 64) + ".jpg",
+	}
+
+	cache := New(t.TempDir())
+
+	for _, name := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := cache.Open(name)
+			if !errors.Is(err, ErrInvalidName) {
+				t.Errorf("Open(%q) error = %v; want %v", name, err, ErrInvalidName)
+			}
+		})
+	}
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	err := png.Encode(&buf, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
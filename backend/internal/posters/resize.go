@@ -0,0 +1,178 @@
+package posters
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	// Register the decoders for the other formats we cache, so that
+	// image.Decode() can read them. WebP isn't in the standard library, so
+	// those posters are only ever served at full size.
+	_ "image/gif"
+	_ "image/png"
+)
+
+// Widths are the sizes posters can be resized to. Keeping to a short list
+// stops clients from filling the cache with a variant for every width.
+var Widths = []int{100, 200, 300, 500}
+
+// variantQuality is the JPEG quality resized posters are saved with.
+const variantQuality = 85
+
+// maxPixels caps the size of the images we'll decode to resize. Decoding
+// takes 4 bytes a pixel, and a small file can claim to be a huge image.
+const maxPixels = 5000 * 5000
+
+// ErrTooLarge is returned when asked to resize an image with more than
+// maxPixels pixels.
+var ErrTooLarge = errors.New("posters: image is too large to resize")
+
+// Resized returns the name of a copy of the cached image name, scaled down to
+// width and saved as a JPEG. Variants are made the first time they're asked
+// for and cached alongside the original. If the image is already no wider
+// than width, or is in a format we can't decode, the original's name is
+// returned instead. That's remembered with an empty marker file, named like
+// the variant but ending in ".orig", so the image isn't read again next time.
+func (c *Cache) Resized(name string, width int) (string, error) {
+	if !nameRX.MatchString(name) {
+		return "", ErrInvalidName
+	}
+
+	base := fmt.Sprintf("%s-w%d", strings.TrimSuffix(name, filepath.Ext(name)), width)
+	variant, marker := base+".jpg", base+".orig"
+
+	ok, err := c.exists(variant)
+	if err != nil || ok {
+		return variant, err
+	}
+
+	ok, err = c.exists(marker)
+	if err != nil || ok {
+		return name, err
+	}
+
+	file, err := c.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	// Check the dimensions, which only needs the header, before decoding the
+	// whole thing.
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return name, c.write(marker, nil)
+		}
+		return "", err
+	}
+
+	if config.Width <= width {
+		return name, c.write(marker, nil)
+	}
+
+	if config.Width*config.Height > maxPixels {
+		return "", ErrTooLarge
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+
+	err = jpeg.Encode(&buf, resize(src, width), &jpeg.Options{Quality: variantQuality})
+	if err != nil {
+		return "", err
+	}
+
+	err = c.write(variant, buf.Bytes())
+	if err != nil {
+		return "", err
+	}
+
+	return variant, nil
+}
+
+// exists reports whether a file is in the cache.
+func (c *Cache) exists(name string) (bool, error) {
+	_, err := os.Stat(filepath.Join(c.dir, name))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, fs.ErrNotExist):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// resize scales src down to width, keeping its aspect ratio. Each pixel of the
+// result is the average of the block of source pixels it covers, which is
+// plenty for shrinking posters. Pixels are read straight from src, and
+// anything transparent ends up white, as JPEG can't store transparency.
+func resize(src image.Image, width int) *image.RGBA {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+
+	height := srcHeight * width / srcWidth
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0, y1 := span(y, height, srcHeight)
+
+		for x := 0; x < width; x++ {
+			x0, x1 := span(x, width, srcWidth)
+
+			var sum [3]uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					// RGBA() is alpha-premultiplied, so laying the pixel
+					// over white means adding white's share, 0xffff - a.
+					r, g, b, a := src.At(bounds.Min.X+sx, bounds.Min.Y+sy).RGBA()
+					sum[0] += uint64(r + 0xffff - a)
+					sum[1] += uint64(g + 0xffff - a)
+					sum[2] += uint64(b + 0xffff - a)
+				}
+			}
+
+			n := uint64((y1 - y0) * (x1 - x0))
+			i := dst.PixOffset(x, y)
+			for j := range sum {
+				dst.Pix[i+j] = uint8(sum[j] / n >> 8)
+			}
+			dst.Pix[i+3] = 0xff
+		}
+	}
+
+	return dst
+}
+
+// span returns the range of source pixels [from, to) covered by pixel i of a
+// dimension scaled from srcSize down to size. It always covers at least one
+// pixel.
+func span(i, size, srcSize int) (int, int) {
+	from := i * srcSize / size
+	to := (i + 1) * srcSize / size
+	if to <= from {
+		to = from + 1
+	}
+	return from, to
+}
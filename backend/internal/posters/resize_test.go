@@ -0,0 +1,194 @@
+package posters
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSpan(t *testing.T) {
+	tests := []struct {
+		name             string
+		i, size, srcSize int
+		wantFrom, wantTo int
+	}{
+		{"halving, first", 0, 100, 200, 0, 2},
+		{"halving, last", 99, 100, 200, 198, 200},
+		{"uneven, first", 0, 3, 10, 0, 3},
+		{"uneven, middle", 1, 3, 10, 3, 6},
+		{"uneven, last", 2, 3, 10, 6, 10},
+		{"same size", 5, 10, 10, 5, 6},
+		{"growing still covers a pixel", 1, 10, 3, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := span(tt.i, tt.size, tt.srcSize)
+			if from != tt.wantFrom || to != tt.wantTo {
+				t.Errorf("span(%d, %d, %d) = [%d, %d); want [%d, %d)", tt.i, tt.size, tt.srcSize, from, to, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestResize(t *testing.T) {
+	tests := []struct {
+		name       string
+		src        image.Image
+		width      int
+		wantBounds image.Rectangle
+		want       color.RGBA
+	}{
+		{
+			name:       "opaque",
+			src:        uniform(image.Rect(0, 0, 40, 60), color.NRGBA{200, 100, 50, 255}),
+			width:      20,
+			wantBounds: image.Rect(0, 0, 20, 30),
+			want:       color.RGBA{200, 100, 50, 255},
+		},
+		{
+			name:       "transparent turns white",
+			src:        uniform(image.Rect(0, 0, 40, 60), color.NRGBA{0, 0, 0, 0}),
+			width:      10,
+			wantBounds: image.Rect(0, 0, 10, 15),
+			want:       color.RGBA{255, 255, 255, 255},
+		},
+		{
+			name:       "half transparent black turns grey",
+			src:        uniform(image.Rect(0, 0, 40, 60), color.NRGBA{0, 0, 0, 128}),
+			width:      10,
+			wantBounds: image.Rect(0, 0, 10, 15),
+			want:       color.RGBA{127, 127, 127, 255},
+		},
+		{
+			name:       "bounds not at the origin",
+			src:        uniform(image.Rect(10, 20, 50, 80), color.NRGBA{10, 20, 30, 255}),
+			width:      20,
+			wantBounds: image.Rect(0, 0, 20, 30),
+			want:       color.RGBA{10, 20, 30, 255},
+		},
+		{
+			name:       "very wide keeps a row",
+			src:        uniform(image.Rect(0, 0, 400, 1), color.NRGBA{1, 2, 3, 255}),
+			width:      100,
+			wantBounds: image.Rect(0, 0, 100, 1),
+			want:       color.RGBA{1, 2, 3, 255},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := resize(tt.src, tt.width)
+
+			if dst.Bounds() != tt.wantBounds {
+				t.Fatalf("got bounds %v; want %v", dst.Bounds(), tt.wantBounds)
+			}
+
+			for _, p := range []image.Point{dst.Bounds().Min, dst.Bounds().Max.Sub(image.Pt(1, 1))} {
+				if got := dst.RGBAAt(p.X, p.Y); got != tt.want {
+					t.Errorf("pixel %v = %v; want %v", p, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestResizeAverages(t *testing.T) {
+	// Alternating black and white columns average out to grey.
+	src := image.NewGray(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x += 2 {
+		src.SetGray(x, 0, color.Gray{255})
+		src.SetGray(x, 1, color.Gray{255})
+	}
+
+	dst := resize(src, 2)
+
+	want := color.RGBA{127, 127, 127, 255}
+	for x := 0; x < 2; x++ {
+		if got := dst.RGBAAt(x, 0); got != want {
+			t.Errorf("pixel %d = %v; want %v", x, got, want)
+		}
+	}
+}
+
+func TestResized(t *testing.T) {
+	poster := encodePNG(t, uniform(image.Rect(0, 0, 400, 600), color.NRGBA{200, 100, 50, 255}))
+	huge := encodePNG(t, image.NewGray(image.Rect(0, 0, 6000, 6000)))
+
+	tests := []struct {
+		name        string
+		body        []byte
+		ext         string
+		width       int
+		wantResized bool // whether a resized copy should be made
+		wantErr     error
+	}{
+		{name: "resized", body: poster, ext: ".png", width: 200, wantResized: true},
+		{name: "already small enough", body: poster, ext: ".png", width: 500},
+		{name: "can't decode", body: []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), ext: ".webp", width: 200},
+		{name: "too many pixels", body: huge, ext: ".png", width: 200, wantErr: ErrTooLarge},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := New(t.TempDir())
+
+			name := strings.Repeat(string(rune('a'+i)), 64) + tt.ext
+
+			err := cache.write(name, tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := cache.Resized(name, tt.width)
+			if err != tt.wantErr {
+				t.Fatalf("got error %v; want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if tt.wantResized {
+				file, err := cache.Open(got)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer file.Close()
+
+				config, _, err := image.DecodeConfig(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if config.Width != tt.width {
+					t.Errorf("got width %d; want %d", config.Width, tt.width)
+				}
+			} else if got != name {
+				t.Errorf("got %q; want the original, %q", got, name)
+			}
+
+			// The outcome is remembered, so the original isn't needed again.
+			err = os.Remove(filepath.Join(cache.dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			again, err := cache.Resized(name, tt.width)
+			if err != nil || again != got {
+				t.Errorf("second call got %q, %v; want %q", again, err, got)
+			}
+		})
+	}
+}
+
+func uniform(r image.Rectangle, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
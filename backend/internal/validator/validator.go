@@ -10,6 +10,18 @@ import "regexp"
 // note further down the page.
 var (
 	EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
+
+	// ImdbIDRX matches IMDb title ids, which look like "tt0319343".
+	ImdbIDRX = regexp.MustCompile(`^tt\d{7,}$`)
+
+	// ColorRX matches hex colors like "#c0392b".
+	ColorRX = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+	// RegionRX matches ISO 3166-1 country codes like "US" or "GB".
+	RegionRX = regexp.MustCompile(`^[A-Z]{2}$`)
+
+	// SlugRX matches lowercase slugs like "amazon-prime-video".
+	SlugRX = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
 )
 
 // Define a new Validator type which contains a map of validation errors.
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS media_user_id_idx;
+
+ALTER TABLE media
+DROP COLUMN IF EXISTS user_id;
+
+DROP TABLE IF EXISTS users;
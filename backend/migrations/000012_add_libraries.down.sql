@@ -0,0 +1,22 @@
+ALTER TABLE media
+ADD COLUMN IF NOT EXISTS user_id bigint REFERENCES users ON DELETE CASCADE;
+
+-- Hand each library's media back to its first owner.
+UPDATE media
+SET user_id = (
+    SELECT user_id FROM library_members
+    WHERE library_members.library_id = media.library_id AND role = 'owner'
+    ORDER BY created_at, user_id
+    LIMIT 1
+);
+
+CREATE INDEX IF NOT EXISTS media_user_id_idx ON media (user_id);
+
+DROP INDEX IF EXISTS media_library_id_idx;
+
+ALTER TABLE media
+DROP COLUMN IF EXISTS library_id;
+
+DROP TABLE IF EXISTS library_invitations;
+DROP TABLE IF EXISTS library_members;
+DROP TABLE IF EXISTS libraries;
@@ -0,0 +1,46 @@
+CREATE TABLE IF NOT EXISTS libraries (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS library_members (
+    library_id bigint NOT NULL REFERENCES libraries ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    role text NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (library_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS library_invitations (
+    hash bytea PRIMARY KEY,
+    library_id bigint NOT NULL REFERENCES libraries ON DELETE CASCADE,
+    invited_by bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    email citext NOT NULL,
+    role text NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    expiry timestamp(0) with time zone NOT NULL
+);
+
+ALTER TABLE media
+ADD COLUMN IF NOT EXISTS library_id bigint REFERENCES libraries ON DELETE CASCADE;
+
+-- Give every existing user a personal library and move their media into it.
+DO $$
+DECLARE
+    u RECORD;
+    lib_id bigint;
+BEGIN
+    FOR u IN SELECT id, name FROM users ORDER BY id LOOP
+        INSERT INTO libraries (name) VALUES (u.name || '''s library') RETURNING id INTO lib_id;
+        INSERT INTO library_members (library_id, user_id, role) VALUES (lib_id, u.id, 'owner');
+        UPDATE media SET library_id = lib_id WHERE user_id = u.id;
+    END LOOP;
+END $$;
+
+DROP INDEX IF EXISTS media_user_id_idx;
+
+ALTER TABLE media
+DROP COLUMN IF EXISTS user_id;
+
+CREATE INDEX IF NOT EXISTS media_library_id_idx ON media (library_id);
@@ -0,0 +1,2 @@
+ALTER TABLE media
+ADD COLUMN IF NOT EXISTS ratings_refreshed_at timestamp(0) with time zone;
@@ -0,0 +1,11 @@
+ALTER TABLE media
+ADD COLUMN IF NOT EXISTS ratings text;
+
+UPDATE media
+SET ratings = (
+    SELECT json_agg(json_build_object('Source', source, 'Value', raw_value) ORDER BY position)::text
+    FROM media_ratings
+    WHERE media_ratings.media_id = media.id
+);
+
+DROP TABLE IF EXISTS media_ratings;
@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS media_ratings (
+    media_id bigint NOT NULL REFERENCES media ON DELETE CASCADE,
+    position smallint NOT NULL,
+    source text NOT NULL,
+    raw_value text NOT NULL,
+    normalized_score numeric(5,2),
+    PRIMARY KEY (media_id, source)
+);
+
+-- Some old rows hold empty strings or otherwise broken JSON in media.ratings,
+-- so parse them leniently and treat anything unreadable as no ratings.
+CREATE FUNCTION pg_temp.parse_ratings(raw text) RETURNS jsonb AS $$
+BEGIN
+    IF raw IS NULL OR raw = '' OR jsonb_typeof(raw::jsonb) <> 'array' THEN
+        RETURN '[]'::jsonb;
+    END IF;
+    RETURN raw::jsonb;
+EXCEPTION WHEN others THEN
+    RETURN '[]'::jsonb;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Scores are normalized to 0-100, so "7.1/10" becomes 71, "85%" stays 85 and
+-- "64/100" stays 64.
+INSERT INTO media_ratings (media_id, position, source, raw_value, normalized_score)
+SELECT DISTINCT ON (media.id, r.value->>'Source')
+    media.id,
+    r.ordinality,
+    r.value->>'Source',
+    r.value->>'Value',
+    CASE
+        WHEN r.value->>'Value' ~ '^[0-9]+(\.[0-9]+)?%$'
+            THEN rtrim(r.value->>'Value', '%')::numeric
+        WHEN r.value->>'Value' ~ '^[0-9]+(\.[0-9]+)?/[0-9]+(\.[0-9]+)?$'
+            THEN round(split_part(r.value->>'Value', '/', 1)::numeric / NULLIF(split_part(r.value->>'Value', '/', 2)::numeric, 0) * 100, 2)
+    END
+FROM media
+CROSS JOIN LATERAL jsonb_array_elements(pg_temp.parse_ratings(media.ratings)) WITH ORDINALITY AS r(value, ordinality)
+WHERE r.value->>'Source' IS NOT NULL AND r.value->>'Value' IS NOT NULL
+ORDER BY media.id, r.value->>'Source', r.ordinality;
+
+ALTER TABLE media
+DROP COLUMN IF EXISTS ratings;
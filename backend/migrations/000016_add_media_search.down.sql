@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS media_search_idx;
+
+ALTER TABLE media
+DROP COLUMN IF EXISTS search;
@@ -0,0 +1,10 @@
+-- The 'simple' configuration doesn't stem or drop stop words, which suits
+-- titles better than a language-specific one, and makes prefix matching
+-- behave the way people expect while they type.
+ALTER TABLE media
+ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(year, '')), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS media_search_idx ON media USING GIN (search);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS media_tags_idx;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS media_tags_idx ON media USING GIN (tags);
@@ -0,0 +1,33 @@
+-- Seasonal windows are stored as 'MM-DD' strings, which sort the same way the
+-- dates do. A window whose end comes before its start wraps around the new
+-- year, like christmas does.
+CREATE TABLE IF NOT EXISTS tags (
+    id bigserial PRIMARY KEY,
+    library_id bigint NOT NULL REFERENCES libraries ON DELETE CASCADE,
+    name text NOT NULL,
+    display_name text NOT NULL,
+    color text NOT NULL DEFAULT '',
+    icon text NOT NULL DEFAULT '',
+    season_start text CHECK (season_start ~ '^\d{2}-\d{2}$'),
+    season_end text CHECK (season_end ~ '^\d{2}-\d{2}$'),
+    version integer NOT NULL DEFAULT 1,
+    UNIQUE (library_id, name),
+    CHECK ((season_start IS NULL) = (season_end IS NULL))
+);
+
+-- Every library starts with the two tags the frontend has always offered.
+INSERT INTO tags (library_id, name, display_name, color, icon, season_start, season_end)
+SELECT libraries.id, defaults.*
+FROM libraries
+CROSS JOIN (VALUES
+    ('christmas', 'Christmas', '#c0392b', '🎄', '11-15', '01-06'),
+    ('halloween', 'Halloween', '#e67e22', '🎃', '10-01', '10-31')
+) AS defaults (name, display_name, color, icon, season_start, season_end)
+ON CONFLICT (library_id, name) DO NOTHING;
+
+-- Define any other tags that are already in use, so existing records stay valid.
+INSERT INTO tags (library_id, name, display_name)
+SELECT DISTINCT library_id, tag, tag
+FROM media, unnest(tags) AS tag
+WHERE library_id IS NOT NULL
+ON CONFLICT (library_id, name) DO NOTHING;
@@ -0,0 +1,7 @@
+ALTER TABLE media
+ALTER COLUMN dateWatched TYPE text USING to_char(dateWatched, 'YYYY-MM-DD');
+
+ALTER TABLE media
+ALTER COLUMN dateWatchedSeasons TYPE text[] USING dateWatchedSeasons::text[];
+
+DROP TABLE IF EXISTS media_date_conversion_failures;
@@ -0,0 +1,74 @@
+-- Dates have been stored as whatever text the client sent, which is usually a
+-- JavaScript ISO timestamp like '2023-12-24T18:30:00.000Z'. Keep the date part
+-- of those, and try anything else as a plain date. Unreadable values become
+-- NULL here and are reported below.
+CREATE FUNCTION pg_temp.parse_date(raw text) RETURNS date AS $$
+BEGIN
+    IF raw ~ '^\d{4}-\d{2}-\d{2}' THEN
+        RETURN left(raw, 10)::date;
+    END IF;
+    RETURN raw::date;
+EXCEPTION WHEN others THEN
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- A season's position in the array is its season number, so seasons that
+-- can't be read become NULL (the same as a season that isn't finished) rather
+-- than being dropped, which would move every later season down one.
+CREATE FUNCTION pg_temp.parse_dates(raw text[]) RETURNS date[] AS $$
+    SELECT CASE WHEN raw IS NULL THEN NULL ELSE array(
+        SELECT pg_temp.parse_date(season)
+        FROM unnest(raw) WITH ORDINALITY AS seasons(season, index)
+        ORDER BY index
+    ) END;
+$$ LANGUAGE sql;
+
+-- dateWatched has to have a value, so an unreadable one falls back to the
+-- first season that was watched, or failing that, to today.
+CREATE FUNCTION pg_temp.parse_date_watched(raw text, seasons text[]) RETURNS date AS $$
+    SELECT coalesce(
+        pg_temp.parse_date(raw),
+        (SELECT min(pg_temp.parse_date(season)) FROM unnest(seasons) AS season),
+        current_date
+    );
+$$ LANGUAGE sql;
+
+-- Every value that couldn't be converted is kept here, along with what it
+-- was replaced with, so it can be fixed by hand. A NULL season_index means the
+-- value was dateWatched, otherwise it's the (1-based) position of the season
+-- in dateWatchedSeasons, which is left as NULL.
+CREATE TABLE IF NOT EXISTS media_date_conversion_failures (
+    media_id bigint NOT NULL REFERENCES media ON DELETE CASCADE,
+    season_index integer,
+    raw_value text,
+    replaced_with date,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO media_date_conversion_failures (media_id, season_index, raw_value)
+SELECT media.id, seasons.index, seasons.raw
+FROM media
+CROSS JOIN LATERAL unnest(media.dateWatchedSeasons) WITH ORDINALITY AS seasons(raw, index)
+WHERE coalesce(seasons.raw, '') <> ''
+AND pg_temp.parse_date(seasons.raw) IS NULL;
+
+INSERT INTO media_date_conversion_failures (media_id, raw_value, replaced_with)
+SELECT id, dateWatched, pg_temp.parse_date_watched(dateWatched, dateWatchedSeasons)
+FROM media
+WHERE pg_temp.parse_date(dateWatched) IS NULL;
+
+ALTER TABLE media
+ALTER COLUMN dateWatched TYPE date USING pg_temp.parse_date_watched(dateWatched, dateWatchedSeasons),
+ALTER COLUMN dateWatchedSeasons TYPE date[] USING pg_temp.parse_dates(dateWatchedSeasons);
+
+DO $$
+DECLARE
+    failures integer;
+BEGIN
+    SELECT count(*) INTO failures FROM media_date_conversion_failures;
+    IF failures > 0 THEN
+        RAISE WARNING '% watched dates could not be converted, see media_date_conversion_failures', failures;
+    END IF;
+END
+$$;
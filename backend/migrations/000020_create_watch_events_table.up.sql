@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS watch_events (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    media_id bigint NOT NULL REFERENCES media ON DELETE CASCADE,
+    watched_on date NOT NULL,
+    rating decimal(3,1),
+    note text NOT NULL DEFAULT '',
+    watched_with text[] NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS watch_events_media_id_idx ON watch_events (media_id, watched_on);
+
+-- Backfill one event for each season date of a watched record, and one for
+-- its dateWatched unless that's already one of the season dates (the frontend
+-- has always sent a series' first season date as its dateWatched too). For
+-- things still on the to-watch list dateWatched is when they were added, so
+-- they don't get an event. Seasons without a date aren't finished, so they
+-- don't get one either.
+INSERT INTO watch_events (media_id, watched_on, note)
+SELECT media.id, seasons.watched_on, 'Season ' || seasons.number
+FROM media
+CROSS JOIN LATERAL unnest(media.dateWatchedSeasons) WITH ORDINALITY AS seasons(watched_on, number)
+WHERE media.watched IS NOT false
+AND seasons.watched_on IS NOT NULL;
+
+INSERT INTO watch_events (media_id, watched_on, rating)
+SELECT id, dateWatched, nullif(rating, 0)
+FROM media
+WHERE watched IS NOT false
+AND NOT dateWatched = ANY(array_remove(coalesce(dateWatchedSeasons, '{}'), NULL));
@@ -0,0 +1,16 @@
+ALTER TABLE media
+ADD COLUMN IF NOT EXISTS dateWatchedSeasons date[];
+
+-- Each position in dateWatchedSeasons is a season number, so unfinished (and
+-- missing) seasons have to stay in the array as NULLs to keep the later ones
+-- in place.
+UPDATE media
+SET dateWatchedSeasons = array(
+    SELECT seasons.watched_on
+    FROM generate_series(1, (SELECT max(number) FROM seasons WHERE seasons.media_id = media.id)) AS numbers(number)
+    LEFT JOIN seasons ON seasons.media_id = media.id AND seasons.number = numbers.number
+    ORDER BY numbers.number
+);
+
+DROP TABLE IF EXISTS episodes;
+DROP TABLE IF EXISTS seasons;
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS seasons (
+    id bigserial PRIMARY KEY,
+    media_id bigint NOT NULL REFERENCES media ON DELETE CASCADE,
+    number integer NOT NULL CHECK (number > 0),
+    episode_count integer NOT NULL DEFAULT 0 CHECK (episode_count >= 0),
+    watched_on date,
+    rating decimal(3,1),
+    UNIQUE (media_id, number)
+);
+
+CREATE TABLE IF NOT EXISTS episodes (
+    id bigserial PRIMARY KEY,
+    season_id bigint NOT NULL REFERENCES seasons ON DELETE CASCADE,
+    number integer NOT NULL CHECK (number > 0),
+    title text NOT NULL DEFAULT '',
+    watched_on date,
+    UNIQUE (season_id, number)
+);
+
+-- The position of each date in dateWatchedSeasons has always meant the season
+-- number, so that's what each one becomes.
+INSERT INTO seasons (media_id, number, watched_on)
+SELECT media.id, seasons.number, seasons.watched_on
+FROM media
+CROSS JOIN LATERAL unnest(media.dateWatchedSeasons) WITH ORDINALITY AS seasons(watched_on, number);
+
+ALTER TABLE media
+DROP COLUMN IF EXISTS dateWatchedSeasons;
@@ -0,0 +1,2 @@
+ALTER TABLE episodes
+DROP COLUMN IF EXISTS marked_at;
@@ -0,0 +1,5 @@
+-- watched_on is the day an episode was watched, which is all people want to
+-- see. marked_at is the moment it was marked, which is what "most recent
+-- activity" is based on.
+ALTER TABLE episodes
+ADD COLUMN IF NOT EXISTS marked_at timestamp(0) with time zone;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS media_provider_refreshes;
+DROP TABLE IF EXISTS media_providers;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS media_providers (
+    media_id bigint NOT NULL REFERENCES media ON DELETE CASCADE,
+    region text NOT NULL,
+    provider_id integer NOT NULL,
+    name text NOT NULL,
+    slug text NOT NULL,
+    logo_path text NOT NULL DEFAULT '',
+    type text NOT NULL CHECK (type IN ('flatrate', 'free', 'ads', 'rent', 'buy')),
+    PRIMARY KEY (media_id, region, provider_id, type)
+);
+
+CREATE INDEX IF NOT EXISTS media_providers_slug_idx ON media_providers (region, slug);
+
+-- When each title's providers were last fetched for a region. This is kept
+-- separately so that a title with no providers is cached too.
+CREATE TABLE IF NOT EXISTS media_provider_refreshes (
+    media_id bigint NOT NULL REFERENCES media ON DELETE CASCADE,
+    region text NOT NULL,
+    refreshed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (media_id, region)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE media_provider_refreshes DROP COLUMN IF EXISTS availability_changed;
+DROP TABLE IF EXISTS user_providers;
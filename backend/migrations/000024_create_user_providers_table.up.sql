@@ -0,0 +1,10 @@
+-- The streaming services each user subscribes to, by provider slug.
+CREATE TABLE IF NOT EXISTS user_providers (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    slug text NOT NULL,
+    PRIMARY KEY (user_id, slug)
+);
+
+-- Whether the last refresh of a title's providers found something different
+-- from the refresh before it.
+ALTER TABLE media_provider_refreshes ADD COLUMN IF NOT EXISTS availability_changed boolean NOT NULL DEFAULT false;
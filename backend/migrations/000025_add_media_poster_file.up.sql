@@ -0,0 +1,3 @@
+-- The name of the locally cached copy of each record's poster, or '' if it
+-- hasn't been downloaded.
+ALTER TABLE media ADD COLUMN IF NOT EXISTS poster_file text NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- Before this migration only the first account to register had media:write.
+DELETE FROM users_permissions
+USING permissions
+WHERE users_permissions.permission_id = permissions.id
+AND permissions.code = 'media:write'
+AND users_permissions.user_id <> (SELECT min(id) FROM users);
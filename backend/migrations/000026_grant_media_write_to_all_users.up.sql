@@ -0,0 +1,7 @@
+-- Library roles now decide who can change which media, so every account gets
+-- media:write, including the ones that registered when only the first
+-- account did.
+INSERT INTO users_permissions
+SELECT users.id, permissions.id FROM users CROSS JOIN permissions
+WHERE permissions.code = 'media:write'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN IF EXISTS personal_library_id;
@@ -0,0 +1,14 @@
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS personal_library_id bigint REFERENCES libraries ON DELETE SET NULL;
+
+-- Until now the personal library was whichever owned library had the lowest
+-- id. A personal library is created when its user registers, so the owned
+-- library a user joined first is the better guess for existing accounts.
+UPDATE users
+SET personal_library_id = (
+    SELECT library_id
+    FROM library_members
+    WHERE library_members.user_id = users.id AND library_members.role = 'owner'
+    ORDER BY library_members.created_at, library_members.library_id
+    LIMIT 1
+);
@@ -0,0 +1,4 @@
+INSERT INTO users_permissions
+SELECT users.id, permissions.id FROM users CROSS JOIN permissions
+WHERE permissions.code = 'media:write'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,17 @@
+-- Accounts are read-only by default again. media:write stays with the first
+-- account, and with owners and editors of shared (not personal) libraries,
+-- who are given it when they accept their invitation.
+DELETE FROM users_permissions
+USING permissions
+WHERE users_permissions.permission_id = permissions.id
+AND permissions.code = 'media:write'
+AND users_permissions.user_id <> (SELECT min(id) FROM users)
+AND NOT EXISTS (
+    SELECT 1
+    FROM library_members
+    WHERE library_members.user_id = users_permissions.user_id
+    AND library_members.role IN ('owner', 'editor')
+    AND library_members.library_id NOT IN (
+        SELECT personal_library_id FROM users WHERE personal_library_id IS NOT NULL
+    )
+);
@@ -6,12 +6,18 @@ import Box from './components/Shared/Box/Box';
 import {useEffect} from 'react';
 import {URL_PATHS} from './types/types';
 import Footer from './components/Footer/Footer';
+import {getToken} from './api/greenlightApi';
 
 function App() {
   const {pathname} = useLocation();
   const navigate = useNavigate();
 
   useEffect(() => {
+    if (!getToken() && pathname !== `/${URL_PATHS.LOGIN}`) {
+      navigate(URL_PATHS.LOGIN);
+      return;
+    }
+
     if (pathname === '/') {
       navigate(URL_PATHS.TO_WATCH);
     }
@@ -1,10 +1,55 @@
 import axios from 'axios';
-import {MediaType, MediaEntity, Tags} from '../types/types';
+import {MediaType, MediaEntity, Tags, URL_PATHS} from '../types/types';
 
 const instance = axios.create({
   baseURL: import.meta.env.VITE_GREENLIGHT_API_URL,
 });
 
+// The API wants a bearer token on every request. We keep the one from the
+// last login in localStorage so it survives reloads.
+const TOKEN_STORAGE_KEY = 'greenlight_token';
+
+export const getToken = (): string | null =>
+  localStorage.getItem(TOKEN_STORAGE_KEY);
+
+// Records come with a signed poster URL (relative to the API's host) that
+// works without the bearer token, so it can go straight into an <img>. width
+// asks the API for a smaller copy.
+export const getPosterUrl = (poster: string, width: number): string => {
+  const url = new URL(
+    poster,
+    new URL(import.meta.env.VITE_GREENLIGHT_API_URL, window.location.href)
+  );
+  url.searchParams.set('w', width.toString());
+
+  return url.toString();
+};
+
+instance.interceptors.request.use((config) => {
+  const token = getToken();
+
+  if (token) {
+    config.headers = config.headers ?? {};
+    config.headers.Authorization = `Bearer ${token}`;
+  }
+
+  return config;
+});
+
+// An expired or revoked token comes back as a 401, so forget it and send the
+// user to the login page.
+instance.interceptors.response.use(undefined, (error) => {
+  if (error.response?.status === 401) {
+    localStorage.removeItem(TOKEN_STORAGE_KEY);
+
+    if (window.location.pathname !== `/${URL_PATHS.LOGIN}`) {
+      window.location.assign(`/${URL_PATHS.LOGIN}`);
+    }
+  }
+
+  return Promise.reject(error);
+});
+
 export type Rating = {
   Source: string;
   Value: string;
@@ -20,7 +65,7 @@ export type CreateWatchedMediaParams = {
   imdbID: string;
   year: string;
   rating: number;
-  ratings: string;
+  ratings: Rating[];
   watched: boolean;
 };
 
@@ -36,35 +81,62 @@ type FetchMediaParams = {
   sort?: string | null;
 };
 
-const greenlightApi = {
-  fetchAllMedia: async (): Promise<MediaEntity[]> => {
-    const {data} = await instance.get('movies');
+// The API returns the media list a page at a time. fetchAllPages follows
+// next_cursor until there are no pages left, so callers get the whole list.
+const MEDIA_PAGE_SIZE = 100;
 
-    return data.media;
-  },
-  fetchWatchedMedia: async (
-    params?: FetchMediaParams
-  ): Promise<MediaEntity[]> => {
+const fetchAllPages = async (params: object = {}): Promise<MediaEntity[]> => {
+  const media: MediaEntity[] = [];
+  let cursor: string | undefined;
+
+  do {
     const {data} = await instance.get('movies', {
       params: {
-        watched: true,
         ...params,
+        page_size: MEDIA_PAGE_SIZE,
+        cursor,
       },
     });
 
-    return data.media;
+    media.push(...data.media);
+    cursor = data.metadata?.next_cursor;
+  } while (cursor);
+
+  return media;
+};
+
+const greenlightApi = {
+  login: async (email: string, password: string): Promise<void> => {
+    const {data} = await instance.post('tokens/authentication', {
+      email,
+      password,
+    });
+
+    localStorage.setItem(TOKEN_STORAGE_KEY, data.authentication_token.token);
+  },
+
+  logout: () => {
+    localStorage.removeItem(TOKEN_STORAGE_KEY);
+  },
+
+  fetchAllMedia: async (): Promise<MediaEntity[]> => {
+    return fetchAllPages();
+  },
+  fetchWatchedMedia: async (
+    params?: FetchMediaParams
+  ): Promise<MediaEntity[]> => {
+    return fetchAllPages({
+      watched: true,
+      ...params,
+    });
   },
   fetchToWatchMedia: async (
     params?: FetchMediaParams
   ): Promise<MediaEntity[]> => {
-    const {data} = await instance.get('movies', {
-      params: {
-        watched: false,
-        ...params,
-      },
+    return fetchAllPages({
+      watched: false,
+      ...params,
     });
-
-    return data.media;
   },
 
   createWatchedMedia: async (
@@ -0,0 +1,62 @@
+import {useQueryClient} from '@tanstack/react-query';
+import {useState} from 'react';
+import {useNavigate} from 'react-router-dom';
+import greenlightApi from '../../api/greenlightApi';
+import {URL_PATHS} from '../../types/types';
+import Box from '../Shared/Box/Box';
+import Button from '../Shared/Button/Button';
+import './Login.less';
+
+const Login = () => {
+  const [email, setEmail] = useState('');
+  const [password, setPassword] = useState('');
+  const [error, setError] = useState('');
+  const navigate = useNavigate();
+  const queryClient = useQueryClient();
+
+  const handleSubmit = async (e: any) => {
+    e.preventDefault();
+    setError('');
+
+    try {
+      await greenlightApi.login(email, password);
+    } catch (err: any) {
+      setError(
+        err.response?.status === 401
+          ? 'Incorrect email or password'
+          : 'Something went wrong, try again'
+      );
+      return;
+    }
+
+    // Anything fetched before logging in was a 401, so start afresh.
+    queryClient.invalidateQueries();
+    navigate(`/${URL_PATHS.TO_WATCH}`);
+  };
+
+  return (
+    <Box className="login-container" flexDirection="column">
+      <h1 style={{fontSize: 24}}>Log in</h1>
+      <form className="login-form" onSubmit={handleSubmit}>
+        <input
+          type="email"
+          placeholder="Email"
+          value={email}
+          onChange={(e) => setEmail(e.target.value)}
+        />
+        <input
+          type="password"
+          placeholder="Password"
+          value={password}
+          onChange={(e) => setPassword(e.target.value)}
+        />
+        {error && <p className="login-error">{error}</p>}
+        <Button type="submit" onClick={() => {}} disabled={!email || !password}>
+          Log in
+        </Button>
+      </form>
+    </Box>
+  );
+};
+
+export default Login;
@@ -6,6 +6,7 @@ import './MediaCard.less';
 import React from 'react';
 import {useQuery} from '@tanstack/react-query';
 import moviedbApi from '../../api/moviedbApi';
+import {getPosterUrl} from '../../api/greenlightApi';
 import classNames from 'classnames';
 
 type WatchedItemProps = {
@@ -14,15 +15,22 @@ type WatchedItemProps = {
   imdbId: string;
   mediaType: MediaType;
   thumbnail: string;
+  poster?: string;
   tags?: string[];
   title: string;
 };
 
+// The poster widths the API can resize to, and how wide the card's image is
+// shown, so the browser can pick the smallest copy that looks sharp.
+const POSTER_WIDTHS = [200, 300, 500];
+const POSTER_SIZES = '(max-width: 1115px) 172px, 300px';
+
 const MediaCard = ({
   id,
   imdbId,
   mediaType,
   thumbnail,
+  poster,
   title,
   tags,
   children,
@@ -37,6 +45,8 @@ const MediaCard = ({
       return apiFn(imdbId);
     },
     retry: false,
+    // Records saved in greenlight have their own poster.
+    enabled: !poster,
   });
 
   const result = fetchItemByType.data;
@@ -57,7 +67,16 @@ const MediaCard = ({
         christmas: tags?.includes(Tags.CHRISTMAS),
       })}
       flexDirection="column">
-      {fetchItemByType.isLoading ? (
+      {poster ? (
+        <img
+          className="media-card-img"
+          src={getPosterUrl(poster, 300)}
+          srcSet={POSTER_WIDTHS.map(
+            (width) => `${getPosterUrl(poster, width)} ${width}w`
+          ).join(', ')}
+          sizes={POSTER_SIZES}
+        />
+      ) : fetchItemByType.isLoading ? (
         <Box className="media-card-img" />
       ) : (
         <img className="media-card-img" src={getThumbnail()} />
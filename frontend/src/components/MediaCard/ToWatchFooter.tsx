@@ -209,7 +209,7 @@ const ToWatchFooter = ({item}: ToWatchFooter): JSX.Element => {
   };
 
   const renderRatings = () => {
-    const ratings = JSON.parse(item.ratings) || [];
+    const ratings = item.ratings || [];
     const visibleRatings = viewportWidth <= 415 ? ratings.slice(0, 2) : ratings;
 
     return (
@@ -54,8 +54,8 @@ const ToWatchList = (): JSX.Element => {
   //TODO: [cam] add default sort direction by sort param (i.e. desc for ratings)
   function sortByRatings(array: MediaEntity[], ratingSource: RatingSource) {
     return array.slice().sort((a: any, b: any) => {
-      const ratingsA = JSON.parse(a.ratings);
-      const ratingsB = JSON.parse(b.ratings);
+      const ratingsA = a.ratings || [];
+      const ratingsB = b.ratings || [];
 
       const ratingA = ratingsA.find((r: Rating) => r.Source === ratingSource);
       const ratingB = ratingsB.find((r: Rating) => r.Source === ratingSource);
@@ -140,6 +140,7 @@ const ToWatchList = (): JSX.Element => {
           imdbId={item.imdbID}
           title={item.title}
           thumbnail={item.thumbnail}
+          poster={item.poster}
           mediaType={item.mediaType}>
           <ToWatchFooter item={item} />
         </MediaCard>
@@ -260,6 +260,7 @@ const WatchedList = (): JSX.Element => {
           imdbId={item.imdbID}
           title={item.title}
           thumbnail={item.thumbnail}
+          poster={item.poster}
           mediaType={item.mediaType}
           tags={item.tags}>
           <Box justifyContent="space-between" alignItems="center" width="100%">
@@ -41,7 +41,7 @@ const convertToMediaEntity = (
     thumbnail: item.Poster,
     imdbID: item.imdbID,
     rating: 0,
-    ratings: JSON.stringify(ratings),
+    ratings,
     watched: false,
   };
 };
@@ -49,7 +49,7 @@ const convertToMediaEntity = (
       thumbnail: item.Poster,
       imdbID: item.imdbID,
       rating,
-      ratings: '',
+      ratings: [],
       watched: true,
     };
   }
@@ -64,7 +64,7 @@ const convertToMediaEntity = (
     thumbnail: item.Poster,
     imdbID: item.imdbID,
     rating,
-    ratings: '',
+    ratings: [],
     watched: true,
   };
 };
@@ -2,6 +2,7 @@ import WatchedList from './components/MediaList/WatchedList';
 import Search from './components/Search/Search';
 import {URL_PATHS} from './types/types';
 import ToWatchList from './components/MediaList/ToWatchList';
+import Login from './components/Login/Login';
 
 const routes = [
   {
@@ -16,6 +17,10 @@ const routes = [
     path: URL_PATHS.SEARCH,
     element: <Search />,
   },
+  {
+    path: URL_PATHS.LOGIN,
+    element: <Login />,
+  },
 ];
 
 export default routes;
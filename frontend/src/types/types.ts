@@ -15,8 +15,9 @@ export type MediaEntity = {
   imdbID: string;
   year: string;
   rating: number;
-  ratings: string;
+  ratings: MediaRating[];
   watched: boolean;
+  poster?: string; // signed /v1/movies/:id/poster URL, if there's a poster
 };
 
 export type SearchResult = {
@@ -71,6 +72,7 @@ export type FindSeriesById = {
 export type MediaRating = {
   Source: string;
   Value: string; //"8.7/10"
+  Score?: number | null; //87, only set on ratings returned by greenlight
 };
 
 type ToWatchMediaEntity = {
@@ -107,6 +109,7 @@ export enum URL_PATHS {
   TO_WATCH = 'to-watch',
   WATCHED = 'watched',
   SEARCH = 'search',
+  LOGIN = 'login',
 }
 
 export type Tab = {